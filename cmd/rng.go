@@ -0,0 +1,80 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/viper"
+)
+
+// rngSource describes where a command's random numbers came from.
+type rngSource struct {
+	mode string
+	seed int64
+}
+
+// String describes the source for verbose output.
+func (s rngSource) String() string {
+	if s.mode == rngCrypto {
+		return "RNG: crypto"
+	}
+	return fmt.Sprintf("Seed: %d", s.seed)
+}
+
+const (
+	rngSeeded = "seeded"
+	rngCrypto = "crypto"
+)
+
+// newRand returns the random source for command. The kind of source comes
+// from --rng, then "<command>.rng" and "rng" in the config, and defaults to
+// seeded. Seeded sources use --seed if it was given and the clock otherwise.
+func newRand(command string) (dice.RandIntn, rngSource, error) {
+	mode := rngMode
+	if !rootCmd.PersistentFlags().Changed("rng") {
+		mode = viper.GetString(command + ".rng")
+		if mode == "" {
+			mode = viper.GetString("rng")
+		}
+		if mode == "" {
+			mode = rngSeeded
+		}
+	}
+
+	switch mode {
+	case rngSeeded:
+		s := time.Now().UnixNano()
+		if rootCmd.PersistentFlags().Changed("seed") {
+			s = seed
+		}
+		return dice.NewSeededRand(s), rngSource{mode: mode, seed: s}, nil
+	case rngCrypto:
+		if rootCmd.PersistentFlags().Changed("seed") {
+			return nil, rngSource{}, fmt.Errorf("--seed can't be used with the crypto RNG")
+		}
+		return dice.CryptoRand{}, rngSource{mode: mode}, nil
+	}
+	return nil, rngSource{}, fmt.Errorf("unknown RNG %q; use %q or %q", mode, rngSeeded, rngCrypto)
+}
@@ -0,0 +1,119 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/viper"
+)
+
+// TestVersionShorthand checks that --verbose doesn't take -v from --version.
+func TestVersionShorthand(t *testing.T) {
+	defer func() {
+		rootCmd.Flags().Set("version", "false")
+		rootCmd.Flags().Lookup("version").Changed = false
+	}()
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetArgs([]string{"-v"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "workbench version ") {
+		t.Errorf("workbench -v printed %q; want the version", buf)
+	}
+}
+
+func TestSeedFlag(t *testing.T) {
+	if err := rootCmd.PersistentFlags().Set("seed", "99"); err != nil {
+		t.Fatalf("Failed to set seed: %v", err)
+	}
+	defer resetFlag(t, "seed", "0")
+
+	r, source, err := newRand("roll")
+	if err != nil {
+		t.Fatalf("newRand returned error %v", err)
+	}
+	if source.seed != 99 || source.String() != "Seed: 99" {
+		t.Errorf("newRand source = %v; want seed 99", source)
+	}
+	if got, want := r.Intn(1000), dice.NewSeededRand(99).Intn(1000); got != want {
+		t.Errorf("newRand with --seed 99 rolled %d; want %d", got, want)
+	}
+}
+
+func TestRNGSelection(t *testing.T) {
+	defer viper.Set("rng", "")
+	defer viper.Set("table.rng", "")
+
+	viper.Set("rng", "crypto")
+	if _, source, err := newRand("roll"); err != nil || source.mode != rngCrypto {
+		t.Errorf("newRand with rng: crypto = %v, %v; want crypto", source, err)
+	}
+
+	viper.Set("table.rng", "seeded")
+	if _, source, err := newRand("table"); err != nil || source.mode != rngSeeded {
+		t.Errorf("newRand with table.rng: seeded = %v, %v; want seeded", source, err)
+	}
+
+	if err := rootCmd.PersistentFlags().Set("rng", "seeded"); err != nil {
+		t.Fatalf("Failed to set rng: %v", err)
+	}
+	defer resetFlag(t, "rng", rngSeeded)
+	if _, source, err := newRand("roll"); err != nil || source.mode != rngSeeded {
+		t.Errorf("newRand with --rng seeded = %v, %v; want the flag to win over config", source, err)
+	}
+}
+
+func TestCryptoRNGRejectsSeed(t *testing.T) {
+	rootCmd.PersistentFlags().Set("rng", "crypto")
+	defer resetFlag(t, "rng", rngSeeded)
+	rootCmd.PersistentFlags().Set("seed", "1")
+	defer resetFlag(t, "seed", "0")
+
+	if _, _, err := newRand("roll"); err == nil {
+		t.Error("newRand with --rng crypto --seed 1 succeeded; want error")
+	}
+}
+
+func TestUnknownRNG(t *testing.T) {
+	rootCmd.PersistentFlags().Set("rng", "dice-bag")
+	defer resetFlag(t, "rng", rngSeeded)
+
+	if _, _, err := newRand("roll"); err == nil {
+		t.Error("newRand with --rng dice-bag succeeded; want error")
+	}
+}
+
+// resetFlag puts a global flag back to its default so later tests don't see
+// it as set.
+func resetFlag(t *testing.T, name, value string) {
+	t.Helper()
+	if err := rootCmd.PersistentFlags().Set(name, value); err != nil {
+		t.Fatalf("Failed to reset %s: %v", name, err)
+	}
+	rootCmd.PersistentFlags().Lookup(name).Changed = false
+}
@@ -22,14 +22,15 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"math"
-	"math/rand"
-	"regexp"
+	"io"
+	"os"
 	"strconv"
 	"strings"
-	"time"
 
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 )
 
@@ -38,7 +39,29 @@ var rollCmd = &cobra.Command{
 	Use:   "roll",
 	Short: "Roll some dice",
 	Long: `Supports complex dice rolls, such as:
-		roll 2d6+1d4+2`,
+		roll 2d6+1d4+2
+		roll "(1d8+3)*2"
+		roll 1d20-1d4
+		roll 4d6kh3     (keep the highest 3)
+		roll 2d20kl1    (disadvantage)
+		roll 1d6!       (exploding; also !! compounding and !p penetrating)
+		roll "1d10!>=9" (explode on 9 or 10)
+		roll "2d6r<3"   (reroll 1s and 2s until they're gone)
+		roll 1d20ro1    (reroll 1s once)
+		roll 2d6min2    (treat rolls below 2 as 2)
+		roll "10d10>=8" (count successes instead of summing)
+		roll "12d6>=5f1" (count 1s as failures)
+		roll 4dF        (Fate dice)
+		roll "d{-1,0,0,1,2,3}"
+		roll "3d{hit,miss,crit}"
+		roll d66        (tens and ones, 11 to 66)
+		roll d100b1     (bonus die; p1 for a penalty die)
+		roll 6x4d6kh3   (roll 6 times; or --repeat 6)
+		roll 2d6+3 --stats  (show the odds instead of rolling)
+		roll 4d6kh3 -o json (print the full result as JSON or YAML)
+		roll 1d20+$str --sheet thorin.yaml (use a character sheet's values)
+		roll -i         (interactive; $_ is the last result)
+		roll 1d20+5 --label attack  (every roll is logged; see roll log)`,
 	Run: func(cmd *cobra.Command, args []string) {
 		expression := "1d20" // default to a d20
 		if len(args) > 0 {
@@ -49,148 +72,337 @@ var rollCmd = &cobra.Command{
 			fmt.Println("Error:", err)
 			return
 		}
-		if !plain {
+		stats, err := cmd.Flags().GetBool("stats")
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if err := checkOutputFormat(output); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		// Structured output replaces the formatted breakdown, and like
+		// --plain keeps anything else off stdout.
+		structured := output != outputText
+		vars, err := sheetVars(cmd)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		interactive, err := cmd.Flags().GetBool("interactive")
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		label, err := cmd.Flags().GetString("label")
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if interactive {
+			r, source, err := newRand("roll")
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if err := runRepl(r, source, vars, newRollLogger(label, source)); err != nil {
+				fmt.Println("Error:", err)
+			}
+			return
+		}
+		count, expression, err := splitRepeat(expression)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		repeated := count > 1 || len(args) > 0 && args[0] != expression
+		if cmd.Flags().Changed("repeat") {
+			if repeated {
+				fmt.Println("Error: use either a repeat count like 6x or --repeat, not both")
+				return
+			}
+			if count, err = cmd.Flags().GetInt("repeat"); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			repeated = true
+		}
+		if !repeated && (cmd.Flags().Changed("sort") || cmd.Flags().Changed("sum")) {
+			fmt.Println("Error: --sort and --sum only apply to repeated rolls")
+			return
+		}
+		if repeated {
+			order, err := cmd.Flags().GetString("sort")
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if err := checkSort(order); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+		}
+		if stats {
+			if repeated {
+				fmt.Println("Error: --stats can't be combined with repeated rolls")
+				return
+			}
+			if structured {
+				fmt.Println("Error: --stats can't be combined with --output")
+				return
+			}
+			if err := printStats(expression, vars); err != nil {
+				printRollError(err, true)
+			}
+			return
+		}
+		if !plain && !structured {
 			fmt.Println()
-			fmt.Println("Rolling:", expression)
+			if repeated {
+				fmt.Printf("Rolling: %s (%d times)\n", expression, count)
+			} else {
+				fmt.Println("Rolling:", expression)
+			}
 			fmt.Println()
 		}
-		result, err := RollDice(rand.New(rand.NewSource(time.Now().UnixNano())), expression)
+		r, source, err := newRand("roll")
 		if err != nil {
 			fmt.Println("Error:", err)
 			return
 		}
+		logger := newRollLogger(label, source)
+		if repeated {
+			rollRepeated(cmd, r, source, logger, expression, count, vars, plain, output)
+			return
+		}
+		result, err := rollDice(r, expression, vars)
+		if err != nil {
+			printRollError(err, !plain && !structured)
+			return
+		}
+		logRolls(logger, result)
+		if verbose {
+			printSource(source, plain || structured)
+		}
+		if structured {
+			if err := writeOutput(os.Stdout, output, result); err != nil {
+				fmt.Println("Error:", err)
+			}
+			return
+		}
 		if plain {
+			if result.Botch {
+				fmt.Print("botch")
+				return
+			}
+			if result.Mode == dice.ModeSymbols {
+				fmt.Print(dice.FormatTally(result.Tally))
+				return
+			}
 			fmt.Printf("%d", result.Total)
 			return
 		}
-		printRoll(result)
+		printRoll(os.Stdout, result)
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(rollCmd)
-	rollCmd.Flags().BoolP("plain", "p", false, "Enable plain output")
+// printRollError prints err, pointing at the problem in the expression if it
+// couldn't be parsed and pointer is set.
+func printRollError(err error, pointer bool) {
+	var parseErr *dice.ParseError
+	if pointer && errors.As(err, &parseErr) {
+		fmt.Println(parseErr.Pointer())
+	}
+	fmt.Println("Error:", err)
 }
 
-type RandIntn interface {
-	Intn(n int) int
+// printSource reports where a roll's random numbers came from, including
+// the seed needed to replay it with --seed. In plain mode it goes to stderr
+// to keep stdout parseable.
+func printSource(source rngSource, plain bool) {
+	if plain {
+		fmt.Fprintln(os.Stderr, source)
+		return
+	}
+	fmt.Printf("%s\n\n", source)
 }
 
-type Die struct {
-	Sides    int
-	Count    int
-	Modifier int
+// parseExpression parses a dice expression, looking up macros in the config
+// and variables in vars.
+func parseExpression(expression string, vars dice.Vars) (*dice.Expression, error) {
+	return dice.Parse(expression, dice.Options{Macros: configuredMacros(), Vars: vars})
 }
 
-func parseDie(die string) (Die, error) {
-	var d Die
-	parts := strings.Split(die, "d")
-	if len(parts) != 2 {
-		return d, fmt.Errorf("invalid die expression: %s", die)
-	}
-	count, err := strconv.Atoi(parts[0])
+// rollDice rolls a dice expression once.
+func rollDice(r dice.RandIntn, expression string, vars dice.Vars) (dice.TotalRollResult, error) {
+	e, err := parseExpression(expression, vars)
 	if err != nil {
-		if parts[0] != "" {
-			return d, fmt.Errorf("invalid die count: %s; error %v", parts[0], err)
-		}
-		count = 1
+		return dice.TotalRollResult{Total: -1}, err
 	}
-	sides, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return d, fmt.Errorf("invalid die sides: %s, error: %v", parts[1], err)
-	}
-	d.Count = count
-	d.Sides = sides
-	return d, nil
+	return e.Roll(r)
 }
 
-func parseExpression(expression string) ([]Die, error) {
-	var dice []Die
-	var modifier int
-
-	// Define a regular expression to capture parts of the dice rolls and modifiers
-	regex := `([+-]?\d*d\d+|[+-]?\d+)`
-	re := regexp.MustCompile(regex)
-
-	// Extract all matches from the string
-	matches := re.FindAllString(expression, -1)
-
-	for _, die := range matches {
-		die = strings.TrimSpace(die)
+func init() {
+	rootCmd.AddCommand(rollCmd)
+	rollCmd.PersistentFlags().String("sheet", "", "Character sheet to take $variables from: a YAML or JSON file, or a name from roll.sheets")
+	rollCmd.Flags().BoolP("plain", "p", false, "Enable plain output")
+	rollCmd.Flags().Bool("stats", false, "Show the odds of each result instead of rolling")
+	rollCmd.Flags().Int("repeat", 1, "Roll the expression this many times")
+	rollCmd.Flags().String("sort", "", `Sort repeated rolls: "asc" or "desc"`)
+	rollCmd.Flags().Bool("sum", false, "Add up repeated rolls")
+	rollCmd.Flags().String("label", "", "Label to record the roll under in the roll log, e.g. attack")
+	rollCmd.Flags().BoolP("interactive", "i", false, "Roll expressions one after another in an interactive session")
+	rollCmd.Flags().StringP("output", "o", "", `Print the full result as "json" or "yaml"`)
+}
 
-		// Die expression
-		if strings.Contains(die, "d") {
-			d, err := parseDie(die)
-			if err != nil {
-				return nil, err
-			}
-			dice = append(dice, d)
-		} else {
-			value, err := strconv.Atoi(die)
-			if err != nil {
-				return nil, fmt.Errorf("invalid modifier: %s", die)
-			}
-			dice[len(dice)-1].Modifier = value
-			modifier += value
+func printRoll(w io.Writer, result dice.TotalRollResult) {
+	width := len(strconv.Itoa(result.Total))
+	for _, r := range result.Results {
+		width = max(width, len(strconv.Itoa(r.Total)))
+	}
+	if result.Mode == dice.ModeSymbols {
+		for _, r := range result.Results {
+			fmt.Fprintf(w, "%8s  %s\n", r.RolledDie, formatRolls(r.Rolls))
 		}
-
+		fmt.Fprintln(w, strings.Repeat("-", 10))
+		fmt.Fprintln(w, dice.FormatTally(result.Tally))
+		return
 	}
-	return dice, nil
-}
-
-type RollResult struct {
-	Total     int
-	RolledDie string
+	for _, r := range result.Results {
+		fmt.Fprintf(w, "%*d %8s  %s\n", width, r.Total, r.RolledDie, formatRolls(r.Rolls))
+	}
+	fmt.Fprintln(w, strings.Repeat("-", width+10))
+	if result.Mode == dice.ModeSuccesses {
+		fmt.Fprintf(w, "%*d  %s\n", width, result.Total, describeSuccesses(result))
+		return
+	}
+	fmt.Fprintf(w, "%*d  = %s%s\n", width, result.Total, result.Breakdown, describeCrits(result))
 }
 
-type TotalRollResult struct {
-	Total   int
-	Results []RollResult
+func describeSuccesses(result dice.TotalRollResult) string {
+	s := plural(result.Successes, "success", "successes")
+	if result.Failures > 0 {
+		s += ", " + plural(result.Failures, "failure", "failures")
+	}
+	if result.Botch {
+		s += " " + failureStyle.Render("(botch!)")
+	}
+	return s + describeCrits(result)
 }
 
-func RollDice(r RandIntn, expression string) (TotalRollResult, error) {
-	dice, err := parseExpression(expression)
-	if err != nil {
-		return TotalRollResult{Total: -1}, err
+// describeCrits notes any critical successes or failures in a roll, or
+// returns "" if there weren't any.
+func describeCrits(result dice.TotalRollResult) string {
+	switch {
+	case result.CritSuccesses == 0 && result.CritFailures == 0:
+		return ""
+	case result.CritSuccesses == 1 && result.CritFailures == 0:
+		return " " + critSuccessStyle.Render("(critical success!)")
+	case result.CritSuccesses == 0 && result.CritFailures == 1:
+		return " " + critFailureStyle.Render("(critical failure!)")
+	}
+	var parts []string
+	if result.CritSuccesses > 0 {
+		parts = append(parts, critSuccessStyle.Render(plural(result.CritSuccesses, "critical success", "critical successes")))
 	}
-	var results []RollResult
-	var total int
-	var max int
-	for _, die := range dice {
-		diceTotal, diceMax, result := generateResult(&die, r)
-		total += diceTotal
-		max += diceMax
-		results = append(results, result)
+	if result.CritFailures > 0 {
+		parts = append(parts, critFailureStyle.Render(plural(result.CritFailures, "critical failure", "critical failures")))
 	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
 
-	if total >= max {
-		return TotalRollResult{Total: -1}, fmt.Errorf("out of bounds somehow! %d > %d", total, max)
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, one)
 	}
-	return TotalRollResult{Total: total, Results: results}, nil
+	return fmt.Sprintf("%d %s", n, many)
 }
 
-func printRoll(result TotalRollResult) {
-	width := int(math.Log10(float64(result.Total))) + 1
-	for _, result := range result.Results {
-		fmt.Printf("%*d %8s\n", width, result.Total, result.RolledDie)
+var (
+	droppedStyle = lipgloss.NewStyle().Strikethrough(true).Faint(true)
+	successStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
+	failureStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)
+
+	critSuccessStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true).Underline(true)
+	critFailureStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true).Underline(true)
+)
+
+func formatRolls(rolls []dice.DieRoll) string {
+	parts := make([]string, len(rolls))
+	for i, roll := range rolls {
+		for _, v := range roll.Rerolls {
+			parts[i] += strikethrough(strconv.Itoa(v)) + ">"
+		}
+		if roll.Floored {
+			parts[i] += strikethrough(strconv.Itoa(roll.Natural)) + ">"
+		}
+		if roll.Face != "" {
+			parts[i] += roll.Face
+		} else {
+			parts[i] += strconv.Itoa(roll.Value)
+		}
+		parts[i] += formatDigits(roll)
+		if len(roll.Chain) > 0 {
+			chain := make([]string, len(roll.Chain))
+			for j, v := range roll.Chain {
+				chain[j] = strconv.Itoa(v)
+			}
+			parts[i] += "(" + strings.Join(chain, "+") + ")"
+		}
+		if roll.Exploded {
+			parts[i] += "!"
+		}
+		if roll.Success {
+			parts[i] = successStyle.Render(parts[i])
+		}
+		if roll.Failure {
+			parts[i] = failureStyle.Render(parts[i])
+		}
+		if roll.CritSuccess {
+			parts[i] = critSuccessStyle.Render(parts[i])
+		}
+		if roll.CritFailure {
+			parts[i] = critFailureStyle.Render(parts[i])
+		}
+		if roll.Dropped {
+			parts[i] = strikethrough(parts[i])
+		}
 	}
-	fmt.Println(strings.Repeat("-", width+10))
-	fmt.Printf("%*d\n", width, result.Total)
+	return "[" + strings.Join(parts, " ") + "]"
 }
 
-func generateResult(die *Die, r RandIntn) (int, int, RollResult) {
-	var diceTotal int
-	var diceMax int
-	for i := 0; i < die.Count; i++ {
-		diceTotal += r.Intn(die.Sides-1) + 1 + die.Modifier
-		diceMax += die.Sides + die.Modifier
+// formatDigits shows the dice that were read positionally to make up a
+// roll: "(3,5)" for a d66, or "(30 ~70~+4)" for a d100 whose bonus or
+// penalty die of 70 wasn't used.
+func formatDigits(roll dice.DieRoll) string {
+	if len(roll.Digits) == 0 {
+		return ""
+	}
+	if len(roll.ExtraTens) == 0 {
+		digits := make([]string, len(roll.Digits))
+		for i, d := range roll.Digits {
+			digits[i] = strconv.Itoa(d)
+		}
+		return "(" + strings.Join(digits, ",") + ")"
 	}
-	var strRepresentation string
-	if die.Modifier != 0 {
-		strRepresentation = fmt.Sprintf("%dd%d+%d", die.Count, die.Sides, die.Modifier)
-	} else {
-		strRepresentation = fmt.Sprintf("%dd%d", die.Count, die.Sides)
+	tens := []string{fmt.Sprintf("%02d", roll.Digits[0]*10)}
+	for _, t := range roll.ExtraTens {
+		tens = append(tens, strikethrough(fmt.Sprintf("%02d", t)))
 	}
+	return "(" + strings.Join(tens, " ") + "+" + strconv.Itoa(roll.Digits[1]) + ")"
+}
 
-	return diceTotal, diceMax, RollResult{Total: diceTotal, RolledDie: strRepresentation}
+// strikethrough marks text as discarded. When the output can't show styles
+// (e.g. it's piped) the text is wrapped in tildes instead.
+func strikethrough(text string) string {
+	styled := droppedStyle.Render(text)
+	if styled == text {
+		return "~" + text + "~"
+	}
+	return styled
 }
@@ -0,0 +1,258 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/cobra"
+)
+
+// minExpectedCount is the smallest expected count a chi-square bin should
+// have for the test to be reliable. Rarer results are merged with their
+// neighbours.
+const minExpectedCount = 5
+
+// rollAuditCmd represents the roll audit command
+var rollAuditCmd = &cobra.Command{
+	Use:   "audit [expression]",
+	Short: "Check that dice rolls are fair",
+	Long: `Roll an expression many times through the normal dice roller and run a
+chi-square goodness-of-fit test comparing how often each result came up with
+how often it should have. Exits with an error if the dice look biased.
+
+Examples:
+  workbench roll audit d20
+  workbench roll audit 4d6kh3 --samples 1000000`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		samples, err := cmd.Flags().GetInt("samples")
+		if err != nil {
+			return fmt.Errorf("error getting samples flag: %w", err)
+		}
+		alpha, err := cmd.Flags().GetFloat64("alpha")
+		if err != nil {
+			return fmt.Errorf("error getting alpha flag: %w", err)
+		}
+		r, source, err := newRand("roll")
+		if err != nil {
+			return err
+		}
+		result, err := auditDice(r, args[0], samples)
+		if err != nil {
+			return err
+		}
+		printAudit(cmd.OutOrStdout(), result, alpha)
+		if verbose {
+			cmd.Printf("\n%s\n", source)
+		}
+		if result.pValue < alpha {
+			return fmt.Errorf("%s looks biased: p-value %.3g is below %g", args[0], result.pValue, alpha)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rollCmd.AddCommand(rollAuditCmd)
+	rollAuditCmd.Flags().Int("samples", 100000, "Number of times to roll")
+	rollAuditCmd.Flags().Float64("alpha", 0.001, "Significance level below which the dice fail")
+}
+
+// auditBin is one cell of a chi-square test, covering the results from lo
+// to hi.
+type auditBin struct {
+	lo, hi   int
+	observed int
+	expected float64
+}
+
+type auditResult struct {
+	expression string
+	samples    int
+	bins       []auditBin
+	chiSquare  float64
+	df         int
+	pValue     float64
+}
+
+// auditDice rolls expression samples times with r and compares the results
+// with its exact distribution using a chi-square goodness-of-fit test.
+func auditDice(r dice.RandIntn, expression string, samples int) (auditResult, error) {
+	if samples < 1 {
+		return auditResult{}, fmt.Errorf("samples must be at least 1")
+	}
+	e, err := parseExpression(expression, nil)
+	if err != nil {
+		return auditResult{}, err
+	}
+	if e.Symbolic() {
+		return auditResult{}, fmt.Errorf("symbolic dice can't be audited")
+	}
+	dist, err := e.Distribution()
+	if err != nil {
+		return auditResult{}, fmt.Errorf("can't audit %s: its odds can't be computed exactly", expression)
+	}
+
+	observed := map[int]int{}
+	for i := 0; i < samples; i++ {
+		result, err := e.Roll(r)
+		if err != nil {
+			return auditResult{}, err
+		}
+		if _, ok := dist[result.Total]; !ok {
+			return auditResult{}, fmt.Errorf("%s rolled %d, which should be impossible", expression, result.Total)
+		}
+		observed[result.Total]++
+	}
+
+	bins := auditBins(dist, observed, samples)
+	if len(bins) < 2 {
+		return auditResult{}, fmt.Errorf("%s doesn't have enough possible results to audit with %d samples", expression, samples)
+	}
+	result := auditResult{expression: expression, samples: samples, bins: bins, df: len(bins) - 1}
+	for _, b := range bins {
+		diff := float64(b.observed) - b.expected
+		result.chiSquare += diff * diff / b.expected
+	}
+	result.pValue = chiSquareSurvival(result.chiSquare, result.df)
+	return result, nil
+}
+
+// auditBins groups the possible results into bins, merging rare results so
+// every bin is expected at least minExpectedCount times.
+func auditBins(dist dice.Distribution, observed map[int]int, samples int) []auditBin {
+	var bins []auditBin
+	var current *auditBin
+	for _, v := range dist.Values() {
+		if current == nil {
+			current = &auditBin{lo: v}
+		}
+		current.hi = v
+		current.observed += observed[v]
+		current.expected += dist[v] * float64(samples)
+		if current.expected >= minExpectedCount {
+			bins = append(bins, *current)
+			current = nil
+		}
+	}
+	if current != nil {
+		if len(bins) == 0 {
+			return []auditBin{*current}
+		}
+		last := &bins[len(bins)-1]
+		last.hi = current.hi
+		last.observed += current.observed
+		last.expected += current.expected
+	}
+	return bins
+}
+
+// chiSquareSurvival is the chance of a chi-square statistic of at least x
+// with df degrees of freedom, i.e. the p-value of the test.
+func chiSquareSurvival(x float64, df int) float64 {
+	return regularizedGammaQ(float64(df)/2, x/2)
+}
+
+// regularizedGammaQ computes the upper regularized incomplete gamma function
+// Q(a, x), using a series when x is small and a continued fraction
+// otherwise.
+func regularizedGammaQ(a, x float64) float64 {
+	const (
+		epsilon    = 1e-15
+		tiny       = 1e-300
+		iterations = 1000
+	)
+	if x <= 0 {
+		return 1
+	}
+	lgamma, _ := math.Lgamma(a)
+	prefix := math.Exp(-x + a*math.Log(x) - lgamma)
+
+	if x < a+1 {
+		term := 1 / a
+		sum := term
+		for n := 1; n < iterations; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if term < sum*epsilon {
+				break
+			}
+		}
+		return 1 - sum*prefix
+	}
+
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < iterations; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return prefix * h
+}
+
+func printAudit(w io.Writer, result auditResult, alpha float64) {
+	labels := make([]string, len(result.bins))
+	width := len("Result")
+	for i, b := range result.bins {
+		labels[i] = fmt.Sprint(b.lo)
+		if b.hi != b.lo {
+			labels[i] = fmt.Sprintf("%d-%d", b.lo, b.hi)
+		}
+		width = max(width, len(labels[i]))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Auditing: %s (%d rolls)\n", result.expression, result.samples)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%*s  %10s  %10s  %8s\n", width, "Result", "Observed", "Expected", "Chi-sq")
+	for i, b := range result.bins {
+		diff := float64(b.observed) - b.expected
+		fmt.Fprintf(w, "%*s  %10d  %10.1f  %8.2f\n", width, labels[i], b.observed, b.expected, diff*diff/b.expected)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Chi-square %.2f with %d degrees of freedom, p-value %.4f\n", result.chiSquare, result.df, result.pValue)
+	if result.pValue < alpha {
+		fmt.Fprintln(w, failureStyle.Render("FAIL: the dice look biased"))
+		return
+	}
+	fmt.Fprintln(w, successStyle.Render("PASS: no sign of bias"))
+}
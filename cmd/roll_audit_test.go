@@ -0,0 +1,117 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+)
+
+// biasedRand reproduces the old off-by-one that never rolled the highest
+// face.
+type biasedRand struct {
+	inner dice.RandIntn
+}
+
+func (b *biasedRand) Intn(n int) int {
+	if n < 2 {
+		return 0
+	}
+	return b.inner.Intn(n - 1)
+}
+
+func TestChiSquareSurvival(t *testing.T) {
+	tests := []struct {
+		x    float64
+		df   int
+		want float64
+	}{
+		{0, 3, 1},
+		{3.841, 1, 0.05},
+		{18.307, 10, 0.05},
+		{2, 2, math.Exp(-1)},
+		{30.144, 19, 0.05},
+	}
+	for _, tt := range tests {
+		if got := chiSquareSurvival(tt.x, tt.df); math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("chiSquareSurvival(%g, %d) = %f; want %f", tt.x, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestAuditFairDice(t *testing.T) {
+	for _, expression := range []string{"d6", "d20", "4d6kh3", "dF"} {
+		result, err := auditDice(dice.NewSeededRand(7), expression, 50000)
+		if err != nil {
+			t.Fatalf("auditDice(%q) returned error: %v", expression, err)
+		}
+		if result.pValue < 0.001 {
+			t.Errorf("auditDice(%q) p-value = %g; want a fair result", expression, result.pValue)
+		}
+	}
+}
+
+func TestAuditCatchesBias(t *testing.T) {
+	result, err := auditDice(&biasedRand{dice.NewSeededRand(7)}, "d20", 50000)
+	if err != nil {
+		t.Fatalf("auditDice returned error: %v", err)
+	}
+	if result.pValue >= 0.001 {
+		t.Errorf("auditDice of biased dice p-value = %g; want a failure", result.pValue)
+	}
+}
+
+func TestAuditBinsMergeRareResults(t *testing.T) {
+	dist := dice.Distribution{1: 0.0001, 2: 0.4999, 3: 0.4999, 4: 0.0001}
+	bins := auditBins(dist, map[int]int{}, 1000)
+	if len(bins) != 2 {
+		t.Fatalf("auditBins returned %d bins; want 2", len(bins))
+	}
+	if bins[0].lo != 1 || bins[0].hi != 2 || bins[1].lo != 3 || bins[1].hi != 4 {
+		t.Errorf("auditBins = %+v; want 1-2 and 3-4", bins)
+	}
+}
+
+func TestAuditRejectsInexactDice(t *testing.T) {
+	for _, expression := range []string{"d6!", "d{hit,miss}"} {
+		if _, err := auditDice(dice.NewSeededRand(1), expression, 100); err == nil {
+			t.Errorf("auditDice(%q) expected an error", expression)
+		}
+	}
+}
+
+func TestRollAuditCommand(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"roll", "audit", "d6", "--samples", "6000"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output := buf.String(); !strings.Contains(output, "PASS") {
+		t.Errorf("Expected a passing audit, got %q", output)
+	}
+}
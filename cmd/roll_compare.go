@@ -0,0 +1,132 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/cobra"
+)
+
+// rollCompareCmd represents the roll compare command
+var rollCompareCmd = &cobra.Command{
+	Use:   "compare [expression] [expression]",
+	Short: "Compare the odds of two dice expressions",
+	Long: `Compare the odds of two dice expressions side by side, including
+their expected values and the chance that each one beats the other.
+
+Examples:
+  workbench roll compare 2d6+3 1d12+3
+  workbench roll compare 2d20kh1 1d20+5`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vars, err := sheetVars(cmd)
+		if err != nil {
+			return err
+		}
+		r, source, err := newRand("roll")
+		if err != nil {
+			return err
+		}
+		a, err := computeStats(r, args[0], vars)
+		if err != nil {
+			return fmt.Errorf("error in %s: %w", args[0], err)
+		}
+		b, err := computeStats(r, args[1], vars)
+		if err != nil {
+			return fmt.Errorf("error in %s: %w", args[1], err)
+		}
+		printComparison(cmd.OutOrStdout(), a, b)
+		if verbose && (!a.exact || !b.exact) {
+			cmd.Printf("\n%s\n", source)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rollCmd.AddCommand(rollCompareCmd)
+}
+
+// compareDistributions works out the chance that a rolls higher than, lower
+// than or the same as b, treating the two rolls as independent.
+func compareDistributions(a, b dice.Distribution) (higher, lower, tie float64) {
+	for x, px := range a {
+		for y, py := range b {
+			switch {
+			case x > y:
+				higher += px * py
+			case x < y:
+				lower += px * py
+			default:
+				tie += px * py
+			}
+		}
+	}
+	return higher, lower, tie
+}
+
+func printComparison(w io.Writer, a, b rollStats) {
+	describe := func(s rollStats) string {
+		if s.exact {
+			return s.expression
+		}
+		return s.expression + fmt.Sprintf(" (estimated from %d rolls)", s.samples)
+	}
+	higher, lower, tie := compareDistributions(a.dist, b.dist)
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "A: %s\n", describe(a))
+	fmt.Fprintf(w, "B: %s\n", describe(b))
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%-10s %10s %10s\n", "", "A", "B")
+	fmt.Fprintf(w, "%-10s %10.2f %10.2f\n", "Mean", a.dist.Mean(), b.dist.Mean())
+	fmt.Fprintf(w, "%-10s %10.2f %10.2f\n", "Std dev", a.dist.StdDev(), b.dist.StdDev())
+	aValues, bValues := a.dist.Values(), b.dist.Values()
+	fmt.Fprintf(w, "%-10s %10d %10d\n", "Min", aValues[0], bValues[0])
+	fmt.Fprintf(w, "%-10s %10d %10d\n", "Max", aValues[len(aValues)-1], bValues[len(bValues)-1])
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "A beats B  %6.2f%%\n", higher*100)
+	fmt.Fprintf(w, "B beats A  %6.2f%%\n", lower*100)
+	fmt.Fprintf(w, "Tie        %6.2f%%\n", tie*100)
+	fmt.Fprintln(w)
+
+	const barWidth = histogramWidth / 2
+	rows := statsRows(min(aValues[0], bValues[0]), max(aValues[len(aValues)-1], bValues[len(bValues)-1]))
+	var tallest float64
+	for _, r := range rows {
+		tallest = max(tallest, a.dist.Between(r.lo, r.hi), b.dist.Between(r.lo, r.hi))
+	}
+	width := len("Result")
+	for _, r := range rows {
+		width = max(width, len(r.label))
+	}
+	fmt.Fprintf(w, "%*s  %7s  %*s  %7s\n", width, "Result", "A", barWidth, "", "B")
+	for _, r := range rows {
+		chanceA, chanceB := a.dist.Between(r.lo, r.hi), b.dist.Between(r.lo, r.hi)
+		fmt.Fprintf(w, "%*s  %6.2f%%  %-*s  %6.2f%%  %s\n", width, r.label,
+			chanceA*100, barWidth, histogramBar(chanceA, tallest, barWidth),
+			chanceB*100, histogramBar(chanceB, tallest, barWidth))
+	}
+}
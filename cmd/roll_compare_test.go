@@ -0,0 +1,66 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+)
+
+func TestCompareDistributions(t *testing.T) {
+	a := dice.Distribution{1: 0.5, 2: 0.5}
+	b := dice.Distribution{1: 0.25, 2: 0.25, 3: 0.5}
+	higher, lower, tie := compareDistributions(a, b)
+	if !almostEqual(higher, 0.125) || !almostEqual(lower, 0.625) || !almostEqual(tie, 0.25) {
+		t.Errorf("compareDistributions = %f, %f, %f; want 0.125, 0.625, 0.25", higher, lower, tie)
+	}
+}
+
+func TestRollCompareCommand(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"roll", "compare", "2d6+3", "1d12+3"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output := buf.String()
+	for _, expected := range []string{"A: 2d6+3", "B: 1d12+3", "A beats B   50.00%", "B beats A   41.67%", "Tie          8.33%"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got %q", expected, output)
+		}
+	}
+}
+
+func TestRollCompareCommandWithBadExpression(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"roll", "compare", "2d6+", "1d12"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
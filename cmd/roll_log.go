@@ -0,0 +1,364 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// rollLogFile is the append-only record of every roll, one JSON object per
+// line, in the data directory.
+const rollLogFile = "roll_log.jsonl"
+
+// Formats for roll log.
+const (
+	logFormatText     = "text"
+	logFormatCSV      = "csv"
+	logFormatMarkdown = "markdown"
+)
+
+// rollLogEntry is one line of the roll log. Seed is the seed the command
+// was run with, so it's unset for crypto rolls. Narrative dice pools are
+// logged with Narrative instead of Result.
+type rollLogEntry struct {
+	Time       time.Time             `json:"time"`
+	Label      string                `json:"label,omitempty"`
+	Expression string                `json:"expression"`
+	RNG        string                `json:"rng"`
+	Seed       *int64                `json:"seed,omitempty"`
+	Result     dice.TotalRollResult  `json:"result,omitzero"`
+	Narrative  *dice.NarrativeResult `json:"narrative,omitempty"`
+}
+
+// rollLogger appends rolls to the roll log. A nil logger logs nothing.
+type rollLogger struct {
+	path   string
+	label  string
+	source rngSource
+	now    func() time.Time
+}
+
+// newRollLogger returns a logger for rolls made with source, or nil if
+// logging has been turned off with roll.log: false in the config. If the
+// data directory can't be created it warns and returns nil, so a read-only
+// home doesn't stop anyone rolling.
+func newRollLogger(label string, source rngSource) *rollLogger {
+	if viper.IsSet("roll.log") && !viper.GetBool("roll.log") {
+		return nil
+	}
+	dir, err := dataDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: rolls won't be logged:", err)
+		return nil
+	}
+	return &rollLogger{path: filepath.Join(dir, rollLogFile), label: label, source: source, now: time.Now}
+}
+
+// entry starts a log entry for a roll of expression.
+func (l *rollLogger) entry(expression string) rollLogEntry {
+	entry := rollLogEntry{
+		Time:       l.now(),
+		Label:      l.label,
+		Expression: expression,
+		RNG:        l.source.mode,
+	}
+	if l.source.mode == rngSeeded {
+		seed := l.source.seed
+		entry.Seed = &seed
+	}
+	return entry
+}
+
+// log appends results to the roll log.
+func (l *rollLogger) log(results ...dice.TotalRollResult) error {
+	if l == nil {
+		return nil
+	}
+	entries := make([]rollLogEntry, len(results))
+	for i, result := range results {
+		entries[i] = l.entry(result.Expression)
+		entries[i].Result = result
+	}
+	return l.write(entries)
+}
+
+// logNarrative appends a roll of narrative dice to the roll log.
+func (l *rollLogger) logNarrative(result dice.NarrativeResult) error {
+	if l == nil {
+		return nil
+	}
+	entry := l.entry(result.Pool)
+	entry.Narrative = &result
+	return l.write([]rollLogEntry{entry})
+}
+
+func (l *rollLogger) write(entries []rollLogEntry) error {
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening roll log: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			f.Close()
+			return fmt.Errorf("error writing roll log: %w", err)
+		}
+	}
+	return f.Close()
+}
+
+// logRolls logs results, warning rather than failing if they can't be
+// written, since the roll itself has already happened.
+func logRolls(l *rollLogger, results ...dice.TotalRollResult) {
+	if err := l.log(results...); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: roll not logged:", err)
+	}
+}
+
+// logNarrative logs a narrative roll, warning rather than failing if it
+// can't be written.
+func logNarrative(l *rollLogger, result dice.NarrativeResult) {
+	if err := l.logNarrative(result); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: roll not logged:", err)
+	}
+}
+
+// rollLogFilter selects entries from the roll log. Zero fields match
+// everything.
+type rollLogFilter struct {
+	since, until time.Time
+	label        string
+	expression   string
+}
+
+func (f rollLogFilter) match(entry rollLogEntry) bool {
+	if !f.since.IsZero() && entry.Time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !entry.Time.Before(f.until) {
+		return false
+	}
+	if f.label != "" && !strings.EqualFold(entry.Label, f.label) {
+		return false
+	}
+	if f.expression != "" && !strings.Contains(strings.ToLower(entry.Expression), strings.ToLower(f.expression)) {
+		return false
+	}
+	return true
+}
+
+// readRollLog returns the entries in the log at path that match filter. A
+// missing log has no entries.
+func readRollLog(path string, filter rollLogFilter) ([]rollLogEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening roll log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []rollLogEntry
+	dec := json.NewDecoder(f)
+	for n := 1; ; n++ {
+		var entry rollLogEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading roll log entry %d: %w", n, err)
+		}
+		if filter.match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// parseLogTime reads a date (2006-01-02) or a full RFC 3339 timestamp. A date
+// on its own means the start of that day in local time, or the start of the
+// next day when endOfDay is set, so --until includes the whole day.
+func parseLogTime(s string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or an RFC 3339 timestamp", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// rollLogRow is the columns an entry is exported as.
+func rollLogRow(entry rollLogEntry) []string {
+	seed := ""
+	if entry.Seed != nil {
+		seed = strconv.FormatInt(*entry.Seed, 10)
+	}
+	return []string{
+		entry.Time.Format(time.RFC3339),
+		entry.Label,
+		entry.Expression,
+		entryTotal(entry),
+		entryBreakdown(entry),
+		entry.RNG,
+		seed,
+	}
+}
+
+// entryTotal is how an entry's result is shown: its total, or what's left
+// of a narrative pool's symbols and whether it succeeded.
+func entryTotal(entry rollLogEntry) string {
+	if n := entry.Narrative; n != nil {
+		outcome := "failed"
+		if n.Succeeded {
+			outcome = "succeeded"
+		}
+		return formatSymbols(n.Net) + " (" + outcome + ")"
+	}
+	return formatTotal(entry.Result)
+}
+
+// entryBreakdown is the breakdown of an entry's result: the faces each
+// narrative die landed on, or the expression with each dice term replaced
+// by what it rolled.
+func entryBreakdown(entry rollLogEntry) string {
+	if n := entry.Narrative; n != nil {
+		faces := make([]string, len(n.Rolls))
+		for i, roll := range n.Rolls {
+			faces[i] = formatFace(roll.Face)
+		}
+		return strings.Join(faces, "; ")
+	}
+	return entry.Result.Breakdown
+}
+
+var rollLogHeader = []string{"time", "label", "expression", "result", "breakdown", "rng", "seed"}
+
+func writeRollLog(w io.Writer, format string, entries []rollLogEntry) error {
+	switch format {
+	case logFormatText:
+		if len(entries) == 0 {
+			fmt.Fprintln(w, "No rolls logged")
+			return nil
+		}
+		for _, entry := range entries {
+			label := ""
+			if entry.Label != "" {
+				label = "[" + entry.Label + "] "
+			}
+			fmt.Fprintf(w, "%s  %s%s = %s", entry.Time.Local().Format(time.DateTime), label, entry.Expression, entryTotal(entry))
+			if entry.Narrative == nil && entry.Result.Mode == dice.ModeSum && entry.Result.Breakdown != formatTotal(entry.Result) {
+				fmt.Fprintf(w, " (%s)", entry.Result.Breakdown)
+			}
+			fmt.Fprintln(w)
+		}
+		return nil
+	case logFormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(rollLogHeader)
+		for _, entry := range entries {
+			cw.Write(rollLogRow(entry))
+		}
+		cw.Flush()
+		return cw.Error()
+	case logFormatMarkdown:
+		fmt.Fprintf(w, "| %s |\n", strings.Join(rollLogHeader, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(rollLogHeader)))
+		for _, entry := range entries {
+			row := rollLogRow(entry)
+			for i, cell := range row {
+				row[i] = strings.ReplaceAll(cell, "|", `\|`)
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q: use %q, %q or %q", format, logFormatText, logFormatCSV, logFormatMarkdown)
+}
+
+// rollLogCmd represents the roll log command
+var rollLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show or export the record of past rolls",
+	Long: `Every roll is recorded in roll_log.jsonl in the data directory, along with
+when it was made, its label and the seed used. This shows the rolls that
+match the filters, or exports them as CSV or Markdown.
+
+Examples:
+  workbench roll log
+  workbench roll log --since 2024-06-01 --label attack
+  workbench roll log --expression d20 --format csv > rolls.csv`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var filter rollLogFilter
+		var err error
+		if since, _ := cmd.Flags().GetString("since"); since != "" {
+			if filter.since, err = parseLogTime(since, false); err != nil {
+				return err
+			}
+		}
+		if until, _ := cmd.Flags().GetString("until"); until != "" {
+			if filter.until, err = parseLogTime(until, true); err != nil {
+				return err
+			}
+		}
+		filter.label, _ = cmd.Flags().GetString("label")
+		filter.expression, _ = cmd.Flags().GetString("expression")
+		format, _ := cmd.Flags().GetString("format")
+
+		dir, err := dataDir()
+		if err != nil {
+			return err
+		}
+		entries, err := readRollLog(filepath.Join(dir, rollLogFile), filter)
+		if err != nil {
+			return err
+		}
+		return writeRollLog(cmd.OutOrStdout(), format, entries)
+	},
+}
+
+func init() {
+	rollCmd.AddCommand(rollLogCmd)
+	rollLogCmd.Flags().String("since", "", "Only show rolls made on or after this date (YYYY-MM-DD)")
+	rollLogCmd.Flags().String("until", "", "Only show rolls made on or before this date (YYYY-MM-DD)")
+	rollLogCmd.Flags().String("label", "", "Only show rolls with this label")
+	rollLogCmd.Flags().String("expression", "", "Only show rolls whose expression contains this text")
+	rollLogCmd.Flags().String("format", logFormatText, `Output format: "text", "csv" or "markdown"`)
+}
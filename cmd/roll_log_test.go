@@ -0,0 +1,274 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/viper"
+)
+
+// testLogger logs to a temporary file, with each roll made a day after the
+// one before starting on 1 June 2024.
+func testLogger(t *testing.T, label string, source rngSource) *rollLogger {
+	t.Helper()
+	day := time.Date(2024, 5, 31, 12, 0, 0, 0, time.Local)
+	return &rollLogger{
+		path:   filepath.Join(t.TempDir(), rollLogFile),
+		label:  label,
+		source: source,
+		now: func() time.Time {
+			day = day.AddDate(0, 0, 1)
+			return day
+		},
+	}
+}
+
+func TestRollLogRoundTrips(t *testing.T) {
+	logger := testLogger(t, "attack", rngSource{mode: rngSeeded, seed: 42})
+	first, _ := rollDice(&mockRand{value: 4}, "1d20+5", nil)
+	second, _ := rollDice(&mockRand{value: 0}, "2d6", nil)
+	if err := logger.log(first, second); err != nil {
+		t.Fatalf("log returned error: %v", err)
+	}
+	logger.label = ""
+	logger.source = rngSource{mode: rngCrypto}
+	third, _ := rollDice(&mockRand{value: 2}, "1d20+1", nil)
+	if err := logger.log(third); err != nil {
+		t.Fatalf("log returned error: %v", err)
+	}
+
+	entries, err := readRollLog(logger.path, rollLogFilter{})
+	if err != nil {
+		t.Fatalf("readRollLog returned error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("readRollLog returned %d entries; want 3", len(entries))
+	}
+	if e := entries[0]; e.Label != "attack" || e.Expression != "1d20+5" || e.Seed == nil || *e.Seed != 42 || e.Result.Total != 10 {
+		t.Errorf("first entry = %+v", e)
+	}
+	if e := entries[2]; e.RNG != rngCrypto || e.Seed != nil || e.Result.Results[0].Rolls[0].Value != 3 {
+		t.Errorf("crypto entry = %+v; want no seed", e)
+	}
+}
+
+func TestRollLogRecordsNarrativeRolls(t *testing.T) {
+	logger := testLogger(t, "stealth", rngSource{mode: rngSeeded, seed: 3})
+	result, err := dice.RollNarrative(&mockRand{value: 0}, "2g1p")
+	if err != nil {
+		t.Fatalf("RollNarrative returned error: %v", err)
+	}
+	if err := logger.logNarrative(result); err != nil {
+		t.Fatalf("logNarrative returned error: %v", err)
+	}
+
+	entries, err := readRollLog(logger.path, rollLogFilter{label: "stealth"})
+	if err != nil {
+		t.Fatalf("readRollLog returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Narrative == nil {
+		t.Fatalf("readRollLog = %+v; want one narrative entry", entries)
+	}
+	e := entries[0]
+	if e.Expression != "2g1p" || e.Narrative.Net != result.Net || len(e.Narrative.Rolls) != 3 {
+		t.Errorf("narrative entry = %+v; want %+v", e.Narrative, result)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writeRollLog(buf, logFormatCSV, entries); err != nil {
+		t.Fatalf("writeRollLog returned error: %v", err)
+	}
+	want := ",stealth,2g1p," + formatSymbols(result.Net) + " (failed),"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("writeRollLog = %q; want it to contain %q", buf, want)
+	}
+}
+
+func TestRollLogFilters(t *testing.T) {
+	logger := testLogger(t, "attack", rngSource{mode: rngSeeded, seed: 1})
+	for _, expression := range []string{"1d20+5", "2d6+3"} {
+		result, _ := rollDice(&mockRand{value: 0}, expression, nil)
+		logger.log(result)
+	}
+	logger.label = "damage"
+	result, _ := rollDice(&mockRand{value: 0}, "1d20", nil)
+	logger.log(result)
+
+	since, _ := parseLogTime("2024-06-02", false)
+	until, _ := parseLogTime("2024-06-02", true)
+	tests := []struct {
+		name   string
+		filter rollLogFilter
+		want   []string
+	}{
+		{"everything", rollLogFilter{}, []string{"1d20+5", "2d6+3", "1d20"}},
+		{"label", rollLogFilter{label: "ATTACK"}, []string{"1d20+5", "2d6+3"}},
+		{"expression", rollLogFilter{expression: "D20"}, []string{"1d20+5", "1d20"}},
+		{"since", rollLogFilter{since: since}, []string{"2d6+3", "1d20"}},
+		{"one day", rollLogFilter{since: since, until: until}, []string{"2d6+3"}},
+		{"nothing", rollLogFilter{label: "initiative"}, nil},
+	}
+	for _, tt := range tests {
+		entries, err := readRollLog(logger.path, tt.filter)
+		if err != nil {
+			t.Fatalf("readRollLog returned error: %v", err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Expression)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadMissingRollLog(t *testing.T) {
+	entries, err := readRollLog(filepath.Join(t.TempDir(), rollLogFile), rollLogFilter{})
+	if err != nil || entries != nil {
+		t.Errorf("readRollLog of a missing log = %v, %v; want nothing", entries, err)
+	}
+}
+
+func TestParseLogTime(t *testing.T) {
+	start, err := parseLogTime("2024-06-01", false)
+	if err != nil || !start.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("parseLogTime(2024-06-01) = %v, %v", start, err)
+	}
+	end, err := parseLogTime("2024-06-01", true)
+	if err != nil || !end.Equal(time.Date(2024, 6, 2, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("parseLogTime(2024-06-01, end of day) = %v, %v", end, err)
+	}
+	exact, err := parseLogTime("2024-06-01T10:30:00Z", true)
+	if err != nil || !exact.Equal(time.Date(2024, 6, 1, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("parseLogTime(RFC 3339) = %v, %v", exact, err)
+	}
+	if _, err := parseLogTime("last tuesday", false); err == nil {
+		t.Error("parseLogTime(last tuesday) expected an error")
+	}
+}
+
+func TestWriteRollLog(t *testing.T) {
+	seed := int64(7)
+	result, _ := rollDice(&mockRand{value: 0}, "2d6+1", nil)
+	entries := []rollLogEntry{{
+		Time:       time.Date(2024, 6, 1, 20, 0, 0, 0, time.UTC),
+		Label:      "a|b",
+		Expression: "2d6+1",
+		RNG:        rngSeeded,
+		Seed:       &seed,
+		Result:     result,
+	}}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{logFormatText, []string{"[a|b] 2d6+1 = 3 (2 + 1)"}},
+		{logFormatCSV, []string{"time,label,expression,result,breakdown,rng,seed", "2024-06-01T20:00:00Z,a|b,2d6+1,3,2 + 1,seeded,7"}},
+		{logFormatMarkdown, []string{"| time | label |", `| 2024-06-01T20:00:00Z | a\|b | 2d6+1 | 3 | 2 + 1 | seeded | 7 |`}},
+	}
+	for _, tt := range tests {
+		buf := new(bytes.Buffer)
+		if err := writeRollLog(buf, tt.format, entries); err != nil {
+			t.Fatalf("writeRollLog(%s) returned error: %v", tt.format, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("writeRollLog(%s) = %q; want it to contain %q", tt.format, buf, want)
+			}
+		}
+	}
+
+	if err := writeRollLog(new(bytes.Buffer), "pdf", entries); err == nil {
+		t.Error("writeRollLog(pdf) expected an error")
+	}
+}
+
+func TestRollLogCanBeDisabled(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("data_dir", t.TempDir())
+	if logger := newRollLogger("", rngSource{}); logger == nil {
+		t.Error("newRollLogger = nil; want a logger by default")
+	}
+	viper.Set("roll.log", false)
+	logger := newRollLogger("", rngSource{})
+	if logger != nil {
+		t.Errorf("newRollLogger with roll.log: false = %v; want nil", logger)
+	}
+	if err := logger.log(dice.TotalRollResult{}); err != nil {
+		t.Errorf("a nil logger returned error: %v", err)
+	}
+}
+
+func TestRollWithUnwritableDataDir(t *testing.T) {
+	defer viper.Reset()
+	// A file where the data directory should be means it can't be created,
+	// whoever runs the tests.
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	viper.Set("data_dir", filepath.Join(blocker, "data"))
+
+	if logger := newRollLogger("", rngSource{}); logger != nil {
+		t.Errorf("newRollLogger = %v; want nil when the data directory can't be created", logger)
+	}
+
+	out := captureStdout(t, func() {
+		rootCmd.SetArgs([]string{"roll", "1d20", "-p", "--seed", "1"})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+	if _, err := strconv.Atoi(strings.TrimSpace(out)); err != nil {
+		t.Errorf("Expected a roll despite the data directory, got %q", out)
+	}
+}
+
+// captureStdout returns what f prints to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read stdout: %v", err)
+	}
+	return string(out)
+}
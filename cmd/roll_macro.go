@@ -0,0 +1,276 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// macrosKey is where roll macros are kept in the config.
+const macrosKey = "roll.macros"
+
+// rollMacroCmd represents the roll macro command
+var rollMacroCmd = &cobra.Command{
+	Use:   "macro",
+	Short: "Save dice expressions under a name",
+	Long: `Save dice expressions you roll often under a name, then roll them with
+@name, on their own or as part of a larger expression.
+
+Examples:
+  workbench roll macro set longsword 1d20+7
+  workbench roll @longsword
+  workbench roll @longsword+1d6
+  workbench roll macro list
+  workbench roll macro delete longsword`,
+}
+
+var rollMacroSetCmd = &cobra.Command{
+	Use:   "set [name] [expression]",
+	Short: "Save a macro",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, expression := strings.ToLower(args[0]), args[1]
+		if err := dice.CheckMacroName(name); err != nil {
+			return err
+		}
+		macros := configuredMacros()
+		macros[name] = expression
+		if _, err := dice.Parse(expression, dice.Options{Macros: macros, AnyVar: true}); err != nil {
+			return err
+		}
+		if err := saveMacros(macros); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Saved @%s = %s\n", name, expression)
+		return nil
+	},
+}
+
+var rollMacroListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved macros",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		macros := configuredMacros()
+		if len(macros) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No macros saved")
+			return
+		}
+		names := make([]string, 0, len(macros))
+		width := 0
+		for name := range macros {
+			names = append(names, name)
+			width = max(width, len(name)+1)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Fprintf(cmd.OutOrStdout(), "%-*s  %s\n", width, "@"+name, macros[name])
+		}
+	},
+}
+
+var rollMacroDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a saved macro",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.ToLower(strings.TrimPrefix(args[0], "@"))
+		macros := configuredMacros()
+		if _, ok := macros[name]; !ok {
+			return fmt.Errorf("no macro named @%s", name)
+		}
+		delete(macros, name)
+		if err := saveMacros(macros); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Deleted @%s\n", name)
+		return nil
+	},
+}
+
+func init() {
+	rollCmd.AddCommand(rollMacroCmd)
+	rollMacroCmd.AddCommand(rollMacroSetCmd, rollMacroListCmd, rollMacroDeleteCmd)
+}
+
+// configuredMacros returns the macros saved in the config, keyed by their
+// lower case names.
+func configuredMacros() map[string]string {
+	return viper.GetStringMapString(macrosKey)
+}
+
+// saveMacros replaces the macros in the config file with macros. Macros
+// already in the file keep their place and comments, and new ones are added
+// at the end in name order.
+func saveMacros(macros map[string]string) error {
+	return updateConfig(func(root *yaml.Node) {
+		roll := mappingChild(root, "roll")
+		if len(macros) == 0 {
+			removeMappingKey(roll, "macros")
+			if len(roll.Content) == 0 {
+				removeMappingKey(root, "roll")
+			}
+			return
+		}
+		saved := mappingChild(roll, "macros")
+		var content []*yaml.Node
+		seen := map[string]bool{}
+		for i := 0; i+1 < len(saved.Content); i += 2 {
+			key, value := saved.Content[i], saved.Content[i+1]
+			name := strings.ToLower(key.Value)
+			expression, ok := macros[name]
+			if !ok || seen[name] {
+				continue
+			}
+			seen[name] = true
+			if value.Kind != yaml.ScalarNode || value.Value != expression {
+				value.SetString(expression)
+			}
+			content = append(content, key, value)
+		}
+		var added []string
+		for name := range macros {
+			if !seen[name] {
+				added = append(added, name)
+			}
+		}
+		slices.Sort(added)
+		for _, name := range added {
+			content = append(content, stringNode(name), stringNode(macros[name]))
+		}
+		saved.Content = content
+	})
+}
+
+func stringNode(s string) *yaml.Node {
+	node := &yaml.Node{}
+	node.SetString(s)
+	return node
+}
+
+// mappingIndex returns the index in mapping.Content of the value for key,
+// or -1 if it isn't there. Keys are matched ignoring case, like viper does.
+func mappingIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if strings.EqualFold(mapping.Content[i].Value, key) {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// mappingChild returns the mapping under key, adding an empty one if there
+// isn't one.
+func mappingChild(mapping *yaml.Node, key string) *yaml.Node {
+	child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	i := mappingIndex(mapping, key)
+	switch {
+	case i < 0:
+		mapping.Content = append(mapping.Content, stringNode(key), child)
+	case mapping.Content[i].Kind == yaml.MappingNode:
+		return mapping.Content[i]
+	default:
+		mapping.Content[i] = child
+	}
+	return child
+}
+
+func removeMappingKey(mapping *yaml.Node, key string) {
+	if i := mappingIndex(mapping, key); i >= 0 {
+		mapping.Content = slices.Delete(mapping.Content, i-1, i+1)
+	}
+}
+
+// configPath is the config file that changes are saved to: the one given by
+// --config, the one that was loaded, or ~/.workbench.yaml.
+func configPath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	if used := viper.ConfigFileUsed(); used != "" {
+		return used, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".workbench.yaml"), nil
+}
+
+// updateConfig edits the config file with update, which is given its
+// top-level mapping, then reloads it. The file is edited as YAML nodes so
+// comments, key order and everything update doesn't touch are kept.
+func updateConfig(update func(root *yaml.Node)) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("error reading config file: %s doesn't hold a mapping of settings", path)
+	}
+	update(root)
+
+	buf := new(bytes.Buffer)
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reloading config file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestRollMacroCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workbench.yaml")
+	original := "# The model for AI commands.\nopenai:\n  model: gpt-4o\nroll:\n  # Don't keep a record of rolls.\n  log: false\n"
+	if err := os.WriteFile(path, []byte(original), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	defer viper.Reset()
+	defer resetFlag(t, "config", "")
+
+	run := func(args ...string) (string, error) {
+		buf := new(bytes.Buffer)
+		rootCmd.SetOut(buf)
+		rootCmd.SetErr(buf)
+		rootCmd.SetArgs(append([]string{"--config", path}, args...))
+		err := rootCmd.Execute()
+		return buf.String(), err
+	}
+
+	if _, err := run("roll", "macro", "set", "Longsword", "1d20+7"); err != nil {
+		t.Fatalf("macro set returned error: %v", err)
+	}
+	if _, err := run("roll", "macro", "set", "bad", "1d20+"); err == nil {
+		t.Error("macro set with a bad expression expected an error")
+	}
+	if _, err := run("roll", "macro", "set", "loop", "@loop"); err == nil {
+		t.Error("macro set with a cycle expected an error")
+	}
+	output, err := run("roll", "macro", "list")
+	if err != nil || !strings.Contains(output, "@longsword  1d20+7") {
+		t.Errorf("macro list = %q, %v; want it to list @longsword", output, err)
+	}
+
+	config, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	want := strings.TrimSuffix(original, "\n") + "\n  macros:\n    longsword: 1d20+7\n"
+	if string(config) != want {
+		t.Errorf("Expected the macro to be added to the config untouched, got %q; want %q", config, want)
+	}
+
+	if _, err := run("roll", "macro", "delete", "@longsword"); err != nil {
+		t.Fatalf("macro delete returned error: %v", err)
+	}
+	if _, err := run("roll", "macro", "delete", "longsword"); err == nil {
+		t.Error("deleting a missing macro expected an error")
+	}
+	if output, _ := run("roll", "macro", "list"); !strings.Contains(output, "No macros saved") {
+		t.Errorf("macro list after delete = %q; want no macros", output)
+	}
+	if config, err := os.ReadFile(path); err != nil || string(config) != original {
+		t.Errorf("Expected deleting the last macro to leave the config as it was, got %q, %v", config, err)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/cobra"
+)
+
+// rollNarrativeCmd represents the roll narrative command
+var rollNarrativeCmd = &cobra.Command{
+	Use:   "narrative [pool]",
+	Short: "Roll Genesys and Star Wars narrative dice",
+	Long: `Roll a pool of the symbol dice used by Genesys and the Star Wars
+roleplaying games. Give the number of each die before its colour letter:
+
+  b  boost (blue)          k  setback (black)
+  g  ability (green)       p  difficulty (purple)
+  y  proficiency (yellow)  r  challenge (red)
+  w  force (white)
+
+Failures cancel successes and threats cancel advantages. Triumphs and
+despairs count as a success and a failure but are never cancelled. The check
+succeeds if at least one success is left.
+
+Examples:
+  workbench roll narrative 2g1y2p1r
+  workbench roll narrative 3g 2p 1b 1k
+  workbench roll narrative 2w -o json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return fmt.Errorf("error getting output flag: %w", err)
+		}
+		if err := checkOutputFormat(output); err != nil {
+			return err
+		}
+		label, err := cmd.Flags().GetString("label")
+		if err != nil {
+			return fmt.Errorf("error getting label flag: %w", err)
+		}
+		r, source, err := newRand("roll")
+		if err != nil {
+			return err
+		}
+		result, err := dice.RollNarrative(r, strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		logNarrative(newRollLogger(label, source), result)
+		if output != outputText {
+			return writeOutput(cmd.OutOrStdout(), output, result)
+		}
+		printNarrative(cmd.OutOrStdout(), result)
+		if verbose {
+			cmd.Printf("\n%s\n", source)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rollCmd.AddCommand(rollNarrativeCmd)
+	rollNarrativeCmd.Flags().StringP("output", "o", "", `Print the full result as "json" or "yaml"`)
+	rollNarrativeCmd.Flags().String("label", "", "Label to record the roll under in the roll log, e.g. stealth")
+}
+
+func printNarrative(w io.Writer, result dice.NarrativeResult) {
+	width := 0
+	for _, roll := range result.Rolls {
+		width = max(width, len(roll.Die))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Rolling: %s\n", result.Pool)
+	fmt.Fprintln(w)
+	for _, roll := range result.Rolls {
+		fmt.Fprintf(w, "%*s  %s\n", width, roll.Die, formatFace(roll.Face))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Rolled: %s\n", describeSymbols(result.Rolled))
+	fmt.Fprintf(w, "Net:    %s\n", describeSymbols(result.Net))
+	if result.Succeeded {
+		fmt.Fprintln(w, successStyle.Render("Succeeded"))
+		return
+	}
+	fmt.Fprintln(w, failureStyle.Render("Failed"))
+}
+
+// formatFace lists the symbols on a rolled face.
+func formatFace(face []dice.Symbol) string {
+	if len(face) == 0 {
+		return "blank"
+	}
+	parts := make([]string, len(face))
+	for i, symbol := range face {
+		parts[i] = string(symbol)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeSymbols summarises symbol counts such as "2 success, 1 threat",
+// highlighting triumphs and despairs.
+func describeSymbols(c dice.SymbolCounts) string {
+	return joinSymbols(c, func(symbol dice.Symbol, part string) string {
+		switch symbol {
+		case dice.Triumph:
+			return successStyle.Render(part)
+		case dice.Despair:
+			return failureStyle.Render(part)
+		}
+		return part
+	})
+}
+
+// formatSymbols summarises symbol counts without any styles, for the roll
+// log.
+func formatSymbols(c dice.SymbolCounts) string {
+	return joinSymbols(c, func(_ dice.Symbol, part string) string { return part })
+}
+
+func joinSymbols(c dice.SymbolCounts, style func(dice.Symbol, string) string) string {
+	var parts []string
+	for _, n := range []struct {
+		count  int
+		symbol dice.Symbol
+	}{
+		{c.Success, dice.Success},
+		{c.Failure, dice.Failure},
+		{c.Advantage, dice.Advantage},
+		{c.Threat, dice.Threat},
+		{c.Triumph, dice.Triumph},
+		{c.Despair, dice.Despair},
+		{c.Light, dice.Light},
+		{c.Dark, dice.Dark},
+	} {
+		if n.count > 0 {
+			parts = append(parts, style(n.symbol, fmt.Sprintf("%d %s", n.count, n.symbol)))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, ", ")
+}
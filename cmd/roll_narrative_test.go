@@ -0,0 +1,93 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+)
+
+func TestDescribeSymbols(t *testing.T) {
+	tests := []struct {
+		counts   dice.SymbolCounts
+		expected string
+	}{
+		{dice.SymbolCounts{}, "nothing"},
+		{dice.SymbolCounts{Success: 2, Threat: 1}, "2 success, 1 threat"},
+		{dice.SymbolCounts{Light: 3, Dark: 1}, "3 light, 1 dark"},
+	}
+	for _, tt := range tests {
+		if got := describeSymbols(tt.counts); got != tt.expected {
+			t.Errorf("describeSymbols(%+v) = %q; want %q", tt.counts, got, tt.expected)
+		}
+	}
+}
+
+func TestRollNarrativeCommand(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"roll", "narrative", "2g1y2p1r", "--seed", "5"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output := buf.String()
+	for _, expected := range []string{"Rolling: 2g1y2p1r", "proficiency", "challenge", "Rolled:", "Net:"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got %q", expected, output)
+		}
+	}
+}
+
+func TestRollNarrativeCommandJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"roll", "narrative", "3g2p", "--seed", "5", "--output", "json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rollNarrativeCmd.Flags().Set("output", "")
+
+	var result dice.NarrativeResult
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", buf.String(), err)
+	}
+	if result.Pool != "3g2p" || len(result.Rolls) != 5 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestRollNarrativeCommandWithBadPool(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"roll", "narrative", "2x"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("Expected error but got none")
+	}
+}
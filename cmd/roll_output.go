@@ -0,0 +1,71 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"go.yaml.in/yaml/v3"
+)
+
+// Output formats for --output. The default, outputText, is the formatted
+// breakdown.
+const (
+	outputText = ""
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+func checkOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q: use %q or %q", format, outputJSON, outputYAML)
+}
+
+// writeOutput writes v to w as JSON or YAML.
+func writeOutput(w io.Writer, format string, v any) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return checkOutputFormat(format)
+}
+
+// repeatedOutput is how repeated rolls are written when they're summed.
+type repeatedOutput struct {
+	Rolls []dice.TotalRollResult `json:"rolls" yaml:"rolls"`
+	Sum   dice.TotalRollResult   `json:"sum" yaml:"sum"`
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"go.yaml.in/yaml/v3"
+)
+
+func TestWriteOutputRoundTrips(t *testing.T) {
+	expressions := []string{"4d6kh3+2", "1d6!>=5ro1min2", "6d10>=8f1", "3d{hit,miss}", "4dF", "2d6!!"}
+	for _, format := range []string{outputJSON, outputYAML} {
+		for _, expression := range expressions {
+			result, err := rollDice(dice.NewSeededRand(5), expression, nil)
+			if err != nil {
+				t.Fatalf("rollDice(%q) returned error: %v", expression, err)
+			}
+			buf := new(bytes.Buffer)
+			if err := writeOutput(buf, format, result); err != nil {
+				t.Fatalf("writeOutput(%s, %q) returned error: %v", format, expression, err)
+			}
+
+			var decoded dice.TotalRollResult
+			if format == outputJSON {
+				err = json.Unmarshal(buf.Bytes(), &decoded)
+			} else {
+				err = yaml.Unmarshal(buf.Bytes(), &decoded)
+			}
+			if err != nil {
+				t.Fatalf("decoding %s output of %q: %v\n%s", format, expression, err, buf)
+			}
+			if !reflect.DeepEqual(decoded, result) {
+				t.Errorf("%s output of %q didn't round trip:\n got %+v\nwant %+v", format, expression, decoded, result)
+			}
+		}
+	}
+}
+
+func TestWriteOutputFields(t *testing.T) {
+	result, err := rollDice(&mockRand{value: 0}, "4d6kh3", nil)
+	if err != nil {
+		t.Fatalf("rollDice returned error: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := writeOutput(buf, outputJSON, result); err != nil {
+		t.Fatalf("writeOutput returned error: %v", err)
+	}
+	for _, expected := range []string{`"expression": "4d6kh3"`, `"notation": "4d6kh3"`, `"keep": "kh"`, `"keep_n": 3`, `"dropped": true`, `"mode": "sum"`} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected JSON output to contain %q, got %s", expected, buf)
+		}
+	}
+}
+
+func TestCheckOutputFormat(t *testing.T) {
+	for _, format := range []string{outputText, outputJSON, outputYAML} {
+		if err := checkOutputFormat(format); err != nil {
+			t.Errorf("checkOutputFormat(%q) returned error: %v", format, err)
+		}
+	}
+	if err := checkOutputFormat("xml"); err == nil {
+		t.Error("checkOutputFormat(xml) expected an error")
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/cobra"
+)
+
+// maxRepeat caps how many times one command can roll an expression.
+const maxRepeat = 1000
+
+// Sort orders for repeated rolls.
+const (
+	sortNone       = ""
+	sortAscending  = "asc"
+	sortDescending = "desc"
+)
+
+// splitRepeat splits a leading repeat count such as the "6x" in "6x4d6kh3"
+// off an expression. Expressions without one are rolled once.
+func splitRepeat(expression string) (int, string, error) {
+	digits := strings.IndexFunc(expression, func(r rune) bool { return !unicode.IsDigit(r) })
+	if digits <= 0 || (expression[digits] != 'x' && expression[digits] != 'X') {
+		return 1, expression, nil
+	}
+	count, err := strconv.Atoi(expression[:digits])
+	if err != nil || count < 1 || count > maxRepeat {
+		return 0, "", fmt.Errorf("repeat count must be between 1 and %d", maxRepeat)
+	}
+	return count, expression[digits+1:], nil
+}
+
+// rollTimes rolls expression count times, parsing it only once.
+func rollTimes(r dice.RandIntn, expression string, count int, vars dice.Vars) ([]dice.TotalRollResult, error) {
+	if count < 1 || count > maxRepeat {
+		return nil, fmt.Errorf("repeat count must be between 1 and %d", maxRepeat)
+	}
+	e, err := parseExpression(expression, vars)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]dice.TotalRollResult, count)
+	for i := range results {
+		results[i], err = e.Roll(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// sortResults orders repeated rolls by their totals. Ties keep the order
+// they were rolled in.
+func sortResults(results []dice.TotalRollResult, order string) error {
+	if err := checkSort(order); err != nil {
+		return err
+	}
+	switch order {
+	case sortAscending:
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Total < results[j].Total })
+	case sortDescending:
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Total > results[j].Total })
+	}
+	return nil
+}
+
+// checkSort returns an error if order isn't a sort order, so that it can be
+// caught before anything is rolled.
+func checkSort(order string) error {
+	switch order {
+	case sortNone, sortAscending, sortDescending:
+		return nil
+	}
+	return fmt.Errorf("unknown sort order %q: use %q or %q", order, sortAscending, sortDescending)
+}
+
+// sumResults adds up a set of repeated rolls into one result.
+func sumResults(results []dice.TotalRollResult) dice.TotalRollResult {
+	var sum dice.TotalRollResult
+	for i, result := range results {
+		if i == 0 {
+			sum.Mode = result.Mode
+		}
+		sum.Total += result.Total
+		sum.Successes += result.Successes
+		sum.Failures += result.Failures
+		sum.Tally = dice.MergeTally(sum.Tally, result.Tally)
+		sum.Results = append(sum.Results, result.Results...)
+	}
+	if sum.Mode == dice.ModeSuccesses {
+		sum.Botch = sum.Total <= 0 && sum.Failures > 0
+	}
+	return sum
+}
+
+// formatTotal is how a result is shown on its own: its total, its tally of
+// symbols, or "botch".
+func formatTotal(result dice.TotalRollResult) string {
+	if result.Botch {
+		return "botch"
+	}
+	if result.Mode == dice.ModeSymbols {
+		return dice.FormatTally(result.Tally)
+	}
+	return strconv.Itoa(result.Total)
+}
+
+// printRepeated prints one line per roll, followed by their sum if sum is
+// set.
+func printRepeated(results []dice.TotalRollResult, sum bool) {
+	totals := make([]string, len(results))
+	width := 0
+	for i, result := range results {
+		totals[i] = formatTotal(result)
+		width = max(width, len(totals[i]))
+	}
+	indexWidth := len(strconv.Itoa(len(results)))
+	for i, result := range results {
+		terms := make([]string, len(result.Results))
+		for j, r := range result.Results {
+			terms[j] = r.RolledDie + " " + formatRolls(r.Rolls)
+		}
+		detail := strings.Join(terms, "  ")
+		if result.Mode == dice.ModeSum && result.Breakdown != totals[i] {
+			detail += "  = " + result.Breakdown
+		}
+		detail += describeCrits(result)
+		fmt.Printf("%*d. %*s  %s\n", indexWidth, i+1, width, totals[i], detail)
+	}
+	if !sum {
+		return
+	}
+	total := sumResults(results)
+	fmt.Println(strings.Repeat("-", indexWidth+width+10))
+	if total.Mode == dice.ModeSuccesses {
+		fmt.Printf("%*s  %s\n", indexWidth+width+2, strconv.Itoa(total.Total), describeSuccesses(total))
+		return
+	}
+	fmt.Printf("%*s  total\n", indexWidth+width+2, formatTotal(total))
+}
+
+// rollRepeated rolls expression count times for rollCmd. In plain mode it
+// prints one total per line, and with structured output a list of results.
+func rollRepeated(cmd *cobra.Command, r dice.RandIntn, source rngSource, logger *rollLogger, expression string, count int, vars dice.Vars, plain bool, output string) {
+	order, err := cmd.Flags().GetString("sort")
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	sum, err := cmd.Flags().GetBool("sum")
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	structured := output != outputText
+	results, err := rollTimes(r, expression, count, vars)
+	if err != nil {
+		printRollError(err, !plain && !structured)
+		return
+	}
+	logRolls(logger, results...)
+	if err := sortResults(results, order); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if verbose {
+		printSource(source, plain || structured)
+	}
+	if structured {
+		var v any = results
+		if sum {
+			v = repeatedOutput{Rolls: results, Sum: sumResults(results)}
+		}
+		if err := writeOutput(os.Stdout, output, v); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	if plain {
+		for _, result := range results {
+			fmt.Println(formatTotal(result))
+		}
+		if sum {
+			fmt.Println(formatTotal(sumResults(results)))
+		}
+		return
+	}
+	printRepeated(results, sum)
+}
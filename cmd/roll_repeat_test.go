@@ -0,0 +1,171 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/viper"
+)
+
+func TestSplitRepeat(t *testing.T) {
+	tests := []struct {
+		input      string
+		count      int
+		expression string
+		wantErr    bool
+	}{
+		{input: "4d6kh3", count: 1, expression: "4d6kh3"},
+		{input: "6x4d6kh3", count: 6, expression: "4d6kh3"},
+		{input: "3X2d6+1", count: 3, expression: "2d6+1"},
+		{input: "10x(1d8+3)*2", count: 10, expression: "(1d8+3)*2"},
+		{input: "0x1d6", wantErr: true},
+		{input: "1001x1d6", wantErr: true},
+	}
+	for _, tt := range tests {
+		count, expression, err := splitRepeat(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("splitRepeat(%q) expected an error", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitRepeat(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if count != tt.count || expression != tt.expression {
+			t.Errorf("splitRepeat(%q) = %d, %q; want %d, %q", tt.input, count, expression, tt.count, tt.expression)
+		}
+	}
+}
+
+func TestRollTimes(t *testing.T) {
+	r := &seqRand{values: []int{0, 1, 2, 3, 4, 5}}
+	results, err := rollTimes(r, "1d6+1", 3, nil)
+	if err != nil {
+		t.Fatalf("rollTimes returned error: %v", err)
+	}
+	var totals []int
+	for _, result := range results {
+		totals = append(totals, result.Total)
+	}
+	if len(totals) != 3 || totals[0] != 2 || totals[1] != 3 || totals[2] != 4 {
+		t.Errorf("rollTimes totals = %v; want [2 3 4]", totals)
+	}
+
+	if _, err := rollTimes(r, "1d6+", 3, nil); err == nil {
+		t.Error("rollTimes with a bad expression expected an error")
+	}
+	if _, err := rollTimes(r, "1d6", 0, nil); err == nil {
+		t.Error("rollTimes with no repeats expected an error")
+	}
+}
+
+func TestSortAndSumResults(t *testing.T) {
+	results := []dice.TotalRollResult{{Total: 8, Breakdown: "a"}, {Total: 14}, {Total: 8, Breakdown: "b"}, {Total: 11}}
+
+	if err := sortResults(results, sortDescending); err != nil {
+		t.Fatalf("sortResults returned error: %v", err)
+	}
+	want := []int{14, 11, 8, 8}
+	for i, result := range results {
+		if result.Total != want[i] {
+			t.Fatalf("sortResults(desc) = %v; want %v", results, want)
+		}
+	}
+	if results[2].Breakdown != "a" || results[3].Breakdown != "b" {
+		t.Errorf("sortResults reordered ties: %v", results)
+	}
+
+	if err := sortResults(results, sortAscending); err != nil || results[0].Total != 8 || results[3].Total != 14 {
+		t.Errorf("sortResults(asc) = %v, %v", results, err)
+	}
+	if err := sortResults(results, "sideways"); err == nil {
+		t.Error("sortResults with an unknown order expected an error")
+	}
+
+	if sum := sumResults(results); sum.Total != 41 {
+		t.Errorf("sumResults total = %d; want 41", sum.Total)
+	}
+}
+
+func TestRollRejectsSortAndSumBeforeRolling(t *testing.T) {
+	defer viper.Reset()
+	dir := t.TempDir()
+	viper.Set("data_dir", dir)
+	defer func() {
+		for name, value := range map[string]string{"sort": "", "sum": "false"} {
+			rollCmd.Flags().Set(name, value)
+			rollCmd.Flags().Lookup(name).Changed = false
+		}
+	}()
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"roll", "3x1d6", "--sort", "sideways"}, `unknown sort order "sideways"`},
+		{[]string{"roll", "1d6", "--sort", "asc"}, "only apply to repeated rolls"},
+		{[]string{"roll", "1d6", "--sum"}, "only apply to repeated rolls"},
+	}
+	for _, tt := range tests {
+		out := captureStdout(t, func() {
+			rootCmd.SetArgs(tt.args)
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+		if !strings.Contains(out, tt.expected) {
+			t.Errorf("%v printed %q; want it to contain %q", tt.args, out, tt.expected)
+		}
+		if strings.Contains(out, "Rolling") {
+			t.Errorf("%v started rolling before rejecting its flags: %q", tt.args, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, rollLogFile)); !os.IsNotExist(err) {
+		t.Errorf("Rejected rolls were logged (stat error %v)", err)
+	}
+}
+
+func TestSumResultsMergesSuccessesAndTallies(t *testing.T) {
+	pools := []dice.TotalRollResult{
+		{Mode: dice.ModeSuccesses, Total: 0, Successes: 0, Failures: 1, Botch: true},
+		{Mode: dice.ModeSuccesses, Total: 2, Successes: 2},
+	}
+	sum := sumResults(pools)
+	if sum.Total != 2 || sum.Failures != 1 || sum.Botch {
+		t.Errorf("sumResults of pools = %+v; want 2 successes, 1 failure and no botch", sum)
+	}
+
+	symbols := []dice.TotalRollResult{
+		{Mode: dice.ModeSymbols, Tally: []dice.FaceCount{{Face: "hit", Count: 1}}},
+		{Mode: dice.ModeSymbols, Tally: []dice.FaceCount{{Face: "hit", Count: 2}, {Face: "miss", Count: 1}}},
+	}
+	if got := formatTotal(sumResults(symbols)); got != "3 hit, 1 miss" {
+		t.Errorf("formatTotal(sumResults(symbols)) = %q; want %q", got, "3 hit, 1 miss")
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// replHistoryFile holds the expressions typed into the dice REPL, one
+	// per line, in the data directory.
+	replHistoryFile = "roll_history"
+	// maxReplHistory caps how many expressions are remembered.
+	maxReplHistory = 1000
+)
+
+// replModel is an interactive dice session. Each expression entered is
+// rolled and its breakdown added to a scrollable log, and $_ refers to the
+// total of the last roll.
+type replModel struct {
+	r        dice.RandIntn
+	source   rngSource
+	vars     dice.Vars
+	input    textinput.Model
+	viewport viewport.Model
+	ready    bool
+	log      []string
+	last     *dice.TotalRollResult
+	logger   *rollLogger
+
+	history     []string
+	historyPath string
+	// recall is the position in history shown by the up and down keys.
+	// len(history) is the line being typed, which is kept in draft.
+	recall int
+	draft  string
+	// err is a problem saving history or logging rolls, shown in place of
+	// the help.
+	err error
+}
+
+func newReplModel(r dice.RandIntn, source rngSource, vars dice.Vars, historyPath string, logger *rollLogger) replModel {
+	input := textinput.New()
+	input.Prompt = "roll> "
+	input.Placeholder = "1d20+5"
+	input.Focus()
+
+	m := replModel{r: r, source: source, vars: vars, input: input, historyPath: historyPath, logger: logger}
+	if historyPath != "" {
+		m.history, m.err = loadReplHistory(historyPath)
+	}
+	m.recall = len(m.history)
+	return m
+}
+
+// runRepl opens the interactive dice session, remembering history in the
+// data directory if it can be created and logging each roll to logger.
+func runRepl(r dice.RandIntn, source rngSource, vars dice.Vars, logger *rollLogger) error {
+	historyPath := ""
+	if dir, err := dataDir(); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: history won't be saved:", err)
+	} else {
+		historyPath = filepath.Join(dir, replHistoryFile)
+	}
+	m := newReplModel(r, source, vars, historyPath, logger)
+	_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+func (m replModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m replModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leave room for the title, input and help lines.
+		height := max(msg.Height-5, 1)
+		if !m.ready {
+			m.viewport = viewport.New(msg.Width, height)
+			m.ready = true
+		} else {
+			m.viewport.Width = msg.Width
+			m.viewport.Height = height
+		}
+		m.viewport.SetContent(strings.Join(m.log, "\n"))
+		m.viewport.GotoBottom()
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC, tea.KeyEsc:
+			return m, tea.Quit
+		case tea.KeyEnter:
+			expression := strings.TrimSpace(m.input.Value())
+			m.input.Reset()
+			if expression == "exit" || expression == "quit" {
+				return m, tea.Quit
+			}
+			if expression != "" {
+				m.roll(expression)
+			}
+			return m, nil
+		case tea.KeyUp:
+			if m.recall > 0 {
+				if m.recall == len(m.history) {
+					m.draft = m.input.Value()
+				}
+				m.recall--
+				m.input.SetValue(m.history[m.recall])
+				m.input.CursorEnd()
+			}
+			return m, nil
+		case tea.KeyDown:
+			if m.recall < len(m.history) {
+				m.recall++
+				if m.recall == len(m.history) {
+					m.input.SetValue(m.draft)
+				} else {
+					m.input.SetValue(m.history[m.recall])
+				}
+				m.input.CursorEnd()
+			}
+			return m, nil
+		case tea.KeyPgUp:
+			m.viewport.PageUp()
+			return m, nil
+		case tea.KeyPgDown:
+			m.viewport.PageDown()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// roll rolls expression, adds it to the log and remembers it in history.
+func (m *replModel) roll(expression string) {
+	vars := maps.Clone(m.vars)
+	if m.last != nil {
+		if vars == nil {
+			vars = dice.Vars{}
+		}
+		vars["_"] = m.last.Total
+	}
+
+	out := new(bytes.Buffer)
+	fmt.Fprintf(out, "> %s\n", expression)
+	result, err := rollDice(m.r, expression, vars)
+	if err != nil {
+		var parseErr *dice.ParseError
+		if errors.As(err, &parseErr) {
+			fmt.Fprintln(out, parseErr.Pointer())
+		}
+		fmt.Fprintln(out, failureStyle.Render("Error: "+err.Error()))
+	} else {
+		printRoll(out, result)
+		m.last = &result
+		if err := m.logger.log(result); err != nil {
+			m.err = err
+		}
+	}
+	m.log = append(m.log, out.String())
+	m.viewport.SetContent(strings.Join(m.log, "\n"))
+	m.viewport.GotoBottom()
+
+	if len(m.history) == 0 || m.history[len(m.history)-1] != expression {
+		m.history = append(m.history, expression)
+		if m.historyPath != "" {
+			if err := appendReplHistory(m.historyPath, expression); err != nil {
+				m.err = fmt.Errorf("error saving history: %w", err)
+			}
+		}
+	}
+	m.recall = len(m.history)
+	m.draft = ""
+}
+
+func (m replModel) View() string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("205")).
+		Bold(true)
+	helpStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240"))
+
+	title := titleStyle.Render("Dice Roller")
+	if verbose {
+		title += helpStyle.Render("  " + m.source.String())
+	}
+	help := "Enter to roll • ↑/↓ history • PgUp/PgDn scroll • $_ is the last result • Esc to quit"
+	if m.err != nil {
+		help = failureStyle.Render(m.err.Error())
+	}
+	return fmt.Sprintf("%s\n\n%s\n%s\n%s", title, m.viewport.View(), m.input.View(), helpStyle.Render(help))
+}
+
+// loadReplHistory reads the saved history, keeping only the most recent
+// maxReplHistory expressions. A missing file is an empty history.
+func loadReplHistory(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var history []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			history = append(history, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(history) > maxReplHistory {
+		history = history[len(history)-maxReplHistory:]
+		if err := os.WriteFile(path, []byte(strings.Join(history, "\n")+"\n"), 0o600); err != nil {
+			return history, err
+		}
+	}
+	return history, nil
+}
+
+func appendReplHistory(path, expression string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, expression); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,152 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// typeLine types text into the REPL and presses enter.
+func typeLine(t *testing.T, m replModel, text string) replModel {
+	t.Helper()
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+	updated, _ = updated.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	return updated.(replModel)
+}
+
+func pressKey(m replModel, key tea.KeyType) replModel {
+	updated, _ := m.Update(tea.KeyMsg{Type: key})
+	return updated.(replModel)
+}
+
+func TestReplRollsAndRemembersLastResult(t *testing.T) {
+	m := newReplModel(&mockRand{value: 4}, rngSource{}, dice.Vars{"str": 3}, "", nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = updated.(replModel)
+
+	m = typeLine(t, m, "1d20+$str")
+	if m.last == nil || m.last.Total != 8 {
+		t.Fatalf("last result = %+v; want 8", m.last)
+	}
+	m = typeLine(t, m, "$_*2")
+	if m.last.Total != 16 {
+		t.Errorf("$_*2 = %d; want 16", m.last.Total)
+	}
+	m = typeLine(t, m, "1d20+")
+	if m.last.Total != 16 {
+		t.Errorf("a bad expression changed the last result to %d", m.last.Total)
+	}
+
+	if len(m.log) != 3 {
+		t.Fatalf("log has %d entries; want 3", len(m.log))
+	}
+	if !strings.Contains(m.log[1], "= $_(8) * 2") {
+		t.Errorf("Expected the breakdown to show $_, got %q", m.log[1])
+	}
+	if !strings.Contains(m.log[2], "Error:") {
+		t.Errorf("Expected an error for a bad expression, got %q", m.log[2])
+	}
+	if view := m.View(); !strings.Contains(view, "roll>") {
+		t.Errorf("Expected the view to show the prompt, got %q", view)
+	}
+}
+
+func TestReplLastResultNeedsARoll(t *testing.T) {
+	m := newReplModel(&mockRand{value: 4}, rngSource{}, nil, "", nil)
+	m = typeLine(t, m, "$_+1")
+	if m.last != nil || !strings.Contains(m.log[0], "unknown variable $_") {
+		t.Errorf("$_ before any roll = %+v, %q; want an unknown variable error", m.last, m.log[0])
+	}
+}
+
+func TestReplHistoryRecall(t *testing.T) {
+	m := newReplModel(&mockRand{value: 0}, rngSource{}, nil, "", nil)
+	m = typeLine(t, m, "1d6")
+	m = typeLine(t, m, "2d8")
+	m = typeLine(t, m, "2d8")
+
+	if len(m.history) != 2 {
+		t.Fatalf("history = %v; want repeated expressions stored once", m.history)
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("3d")})
+	m = updated.(replModel)
+	m = pressKey(m, tea.KeyUp)
+	if got := m.input.Value(); got != "2d8" {
+		t.Errorf("up = %q; want 2d8", got)
+	}
+	m = pressKey(m, tea.KeyUp)
+	m = pressKey(m, tea.KeyUp)
+	if got := m.input.Value(); got != "1d6" {
+		t.Errorf("up past the start = %q; want 1d6", got)
+	}
+	m = pressKey(m, tea.KeyDown)
+	m = pressKey(m, tea.KeyDown)
+	if got := m.input.Value(); got != "3d" {
+		t.Errorf("down back to the end = %q; want the draft 3d", got)
+	}
+}
+
+func TestReplHistoryIsSaved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), replHistoryFile)
+	m := newReplModel(&mockRand{value: 0}, rngSource{}, nil, path, nil)
+	m = typeLine(t, m, "1d6")
+	m = typeLine(t, m, "4d6kh3")
+	if m.err != nil {
+		t.Fatalf("saving history returned error: %v", m.err)
+	}
+
+	again := newReplModel(&mockRand{value: 0}, rngSource{}, nil, path, nil)
+	if len(again.history) != 2 || again.history[1] != "4d6kh3" {
+		t.Errorf("loaded history = %v; want [1d6 4d6kh3]", again.history)
+	}
+	again = pressKey(again, tea.KeyUp)
+	if got := again.input.Value(); got != "4d6kh3" {
+		t.Errorf("up in a new session = %q; want 4d6kh3", got)
+	}
+}
+
+func TestLoadReplHistoryTrims(t *testing.T) {
+	path := filepath.Join(t.TempDir(), replHistoryFile)
+	lines := make([]string, maxReplHistory+10)
+	for i := range lines {
+		lines[i] = "1d6"
+	}
+	lines[len(lines)-1] = "1d20"
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+
+	history, err := loadReplHistory(path)
+	if err != nil {
+		t.Fatalf("loadReplHistory returned error: %v", err)
+	}
+	if len(history) != maxReplHistory || history[len(history)-1] != "1d20" {
+		t.Errorf("loadReplHistory kept %d entries ending in %q", len(history), history[len(history)-1])
+	}
+}
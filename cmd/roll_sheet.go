@@ -0,0 +1,96 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// sheetVars loads the character sheet chosen with --sheet, or the default
+// sheet in the config, if there is one. A sheet can be a path or the name of
+// one listed under roll.sheets.
+func sheetVars(cmd *cobra.Command) (dice.Vars, error) {
+	sheet, err := cmd.Flags().GetString("sheet")
+	if err != nil {
+		return nil, fmt.Errorf("error getting sheet flag: %w", err)
+	}
+	if sheet == "" {
+		sheet = viper.GetString("roll.sheet")
+	}
+	if sheet == "" {
+		return nil, nil
+	}
+	if path := viper.GetStringMapString("roll.sheets")[strings.ToLower(sheet)]; path != "" {
+		sheet = path
+	}
+	return loadSheet(sheet)
+}
+
+// loadSheet reads the variables in a YAML or JSON character sheet. Nested
+// values are named with dots, e.g. skills.stealth. Values that aren't whole
+// numbers, such as the character's name, are skipped.
+func loadSheet(path string) (dice.Vars, error) {
+	path, err := expandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("error reading character sheet: %w", err)
+	}
+	sheet := viper.New()
+	sheet.SetConfigFile(path)
+	if err := sheet.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading character sheet: %w", err)
+	}
+	vars := dice.Vars{}
+	for _, key := range sheet.AllKeys() {
+		if value, ok := wholeNumber(sheet.Get(key)); ok {
+			vars[key] = value
+		}
+	}
+	return vars, nil
+}
+
+func wholeNumber(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case uint64:
+		return int(n), n <= math.MaxInt
+	case float64:
+		return int(n), n == math.Trunc(n) && math.Abs(n) <= math.MaxInt32
+	case string:
+		value, err := strconv.Atoi(strings.TrimSpace(n))
+		return value, err == nil
+	}
+	return 0, false
+}
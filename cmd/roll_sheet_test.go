@@ -0,0 +1,93 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/viper"
+)
+
+func writeSheet(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Failed to write sheet: %v", err)
+	}
+	return path
+}
+
+func TestLoadSheet(t *testing.T) {
+	yamlSheet := writeSheet(t, "thorin.yaml", "name: Thorin\nSTR: 3\nprof: \"2\"\nskills:\n  stealth: -1\nspeed: 7.5\n")
+	jsonSheet := writeSheet(t, "elara.json", `{"dex": 4, "skills": {"arcana": 6}}`)
+
+	tests := []struct {
+		path string
+		want dice.Vars
+	}{
+		{yamlSheet, dice.Vars{"str": 3, "prof": 2, "skills.stealth": -1}},
+		{jsonSheet, dice.Vars{"dex": 4, "skills.arcana": 6}},
+	}
+	for _, tt := range tests {
+		vars, err := loadSheet(tt.path)
+		if err != nil {
+			t.Fatalf("loadSheet(%s) returned error: %v", tt.path, err)
+		}
+		if len(vars) != len(tt.want) {
+			t.Errorf("loadSheet(%s) = %v; want %v", tt.path, vars, tt.want)
+		}
+		for name, value := range tt.want {
+			if vars[name] != value {
+				t.Errorf("loadSheet(%s)[%s] = %d; want %d", tt.path, name, vars[name], value)
+			}
+		}
+	}
+
+	if _, err := loadSheet(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("loadSheet of a missing file expected an error")
+	}
+}
+
+func TestSheetProfiles(t *testing.T) {
+	path := writeSheet(t, "thorin.yaml", "str: 3\n")
+	defer viper.Reset()
+	viper.Set("roll.sheets", map[string]any{"thorin": path})
+
+	if vars, err := sheetVars(rollCmd); err != nil || vars != nil {
+		t.Errorf("sheetVars with no sheet = %v, %v; want none", vars, err)
+	}
+
+	viper.Set("roll.sheet", "Thorin")
+	vars, err := sheetVars(rollCmd)
+	if err != nil || vars["str"] != 3 {
+		t.Errorf("sheetVars with roll.sheet = %v, %v; want str 3", vars, err)
+	}
+
+	viper.Set("roll.sheet", "nobody")
+	if _, err := sheetVars(rollCmd); err == nil || !strings.Contains(err.Error(), "nobody") {
+		t.Errorf("sheetVars with an unknown sheet = %v; want an error", err)
+	}
+}
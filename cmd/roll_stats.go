@@ -0,0 +1,176 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+)
+
+const (
+	// monteCarloSamples is how many times an expression is rolled when its
+	// distribution can't be worked out exactly.
+	monteCarloSamples = 100000
+	// maxSampledDice caps the dice rolled while estimating a distribution,
+	// so huge pools are rolled fewer times, though never fewer than
+	// minMonteCarloSamples.
+	maxSampledDice       = 2000000
+	minMonteCarloSamples = 1000
+	// maxStatsRows is the most rows printStats shows before grouping
+	// results into ranges.
+	maxStatsRows   = 40
+	histogramWidth = 40
+)
+
+// rollStats is the distribution of an expression. exact is false when the
+// distribution was estimated by rolling the expression samples times.
+type rollStats struct {
+	expression string
+	exact      bool
+	samples    int
+	dist       dice.Distribution
+}
+
+// computeStats works out the distribution of expression, exactly where
+// possible and otherwise by rolling it many times with r.
+func computeStats(r dice.RandIntn, expression string, vars dice.Vars) (rollStats, error) {
+	e, err := parseExpression(expression, vars)
+	if err != nil {
+		return rollStats{}, err
+	}
+	if e.Symbolic() {
+		return rollStats{}, fmt.Errorf("stats aren't available for symbolic dice")
+	}
+	// Roll once so problems like mixing success pools and sums are reported
+	// the same way they would be for a normal roll.
+	first, err := e.Roll(r)
+	if err != nil {
+		return rollStats{}, err
+	}
+
+	dist, err := e.Distribution()
+	if err == nil {
+		return rollStats{expression: expression, exact: true, dist: dist}, nil
+	}
+	if !errors.Is(err, dice.ErrInexact) {
+		return rollStats{}, err
+	}
+	samples := sampleCount(first)
+	dist, err = e.Sample(r, samples)
+	if err != nil {
+		return rollStats{}, err
+	}
+	return rollStats{expression: expression, exact: false, samples: samples, dist: dist}, nil
+}
+
+// sampleCount is how many times to roll an expression to estimate its
+// distribution, given one roll of it: monteCarloSamples, or fewer for pools
+// so big that would mean rolling more than maxSampledDice dice.
+func sampleCount(first dice.TotalRollResult) int {
+	n := 0
+	for _, result := range first.Results {
+		n += len(result.Rolls)
+	}
+	return max(minMonteCarloSamples, min(monteCarloSamples, maxSampledDice/max(n, 1)))
+}
+
+func printStats(expression string, vars dice.Vars) error {
+	r, source, err := newRand("roll")
+	if err != nil {
+		return err
+	}
+	stats, err := computeStats(r, expression, vars)
+	if err != nil {
+		return err
+	}
+	method := "exact"
+	if !stats.exact {
+		method = fmt.Sprintf("estimated from %d rolls", stats.samples)
+	}
+	values := stats.dist.Values()
+
+	fmt.Println()
+	fmt.Printf("Stats for: %s (%s)\n", expression, method)
+	fmt.Println()
+	fmt.Printf("Mean     %8.2f\n", stats.dist.Mean())
+	fmt.Printf("Std dev  %8.2f\n", stats.dist.StdDev())
+	fmt.Printf("Min      %8d\n", values[0])
+	fmt.Printf("Max      %8d\n", values[len(values)-1])
+	fmt.Println()
+	printDistribution(stats.dist)
+	if verbose && !stats.exact {
+		fmt.Println()
+		fmt.Println(source)
+	}
+	return nil
+}
+
+// printDistribution prints the chance of each result along with the chance
+// of rolling at least that much and a histogram bar.
+func printDistribution(dist dice.Distribution) {
+	values := dist.Values()
+	rows := statsRows(values[0], values[len(values)-1])
+	var tallest float64
+	for _, r := range rows {
+		tallest = max(tallest, dist.Between(r.lo, r.hi))
+	}
+
+	width := len("Result")
+	for _, r := range rows {
+		width = max(width, len(r.label))
+	}
+	fmt.Printf("%*s  %7s  %8s\n", width, "Result", "Chance", "At least")
+	for _, r := range rows {
+		chance := dist.Between(r.lo, r.hi)
+		fmt.Printf("%*s  %6.2f%%  %7.2f%%  %s\n", width, r.label, chance*100, dist.AtLeast(r.lo)*100, histogramBar(chance, tallest, histogramWidth))
+	}
+}
+
+// statsRow is one line of a distribution table, covering the results from
+// lo to hi.
+type statsRow struct {
+	lo, hi int
+	label  string
+}
+
+// statsRows splits the results from lo to hi into table rows. Wide
+// distributions are grouped into ranges so the table stays readable.
+func statsRows(lo, hi int) []statsRow {
+	step := (hi-lo)/maxStatsRows + 1
+	var rows []statsRow
+	for start := lo; start <= hi; start += step {
+		r := statsRow{lo: start, hi: min(start+step-1, hi), label: fmt.Sprint(start)}
+		if r.hi != r.lo {
+			r.label = fmt.Sprintf("%d-%d", r.lo, r.hi)
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+func histogramBar(chance, tallest float64, width int) string {
+	return strings.Repeat("#", int(math.Round(chance/tallest*float64(width))))
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestComputeStatsFallsBackToSampling(t *testing.T) {
+	stats, err := computeStats(&seqRand{values: []int{0, 1, 2}}, "1d6!", nil)
+	if err != nil {
+		t.Fatalf("computeStats returned error %v", err)
+	}
+	if stats.exact {
+		t.Error("computeStats(1d6!) claims to be exact")
+	}
+	if !almostEqual(stats.dist.Mean(), 2) {
+		t.Errorf("computeStats(1d6!) mean = %f; want 2", stats.dist.Mean())
+	}
+	if stats.samples != monteCarloSamples {
+		t.Errorf("computeStats(1d6!) rolled %d times; want %d", stats.samples, monteCarloSamples)
+	}
+}
+
+func TestComputeStatsSamplesHugePoolsLess(t *testing.T) {
+	stats, err := computeStats(dice.NewSeededRand(1), "3000d6", nil)
+	if err != nil {
+		t.Fatalf("computeStats returned error %v", err)
+	}
+	if stats.exact {
+		t.Error("computeStats(3000d6) claims to be exact")
+	}
+	if stats.samples != minMonteCarloSamples {
+		t.Errorf("computeStats(3000d6) rolled %d times; want %d", stats.samples, minMonteCarloSamples)
+	}
+	if mean := stats.dist.Mean(); mean < 10450 || mean > 10550 {
+		t.Errorf("computeStats(3000d6) mean = %f; want about 10500", mean)
+	}
+}
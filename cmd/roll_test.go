@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
 )
 
 // mockRand is a mock random number generator that always returns a fixed value
@@ -13,119 +16,48 @@ func (r *mockRand) Intn(n int) int {
 	return r.value
 }
 
-func TestBasicSixSidedDie(t *testing.T) {
-	r := &mockRand{value: 3}
-	input := "1d6"
-	expected := 4
-	result, err := RollDice(r, input)
-
-	if err != nil {
-		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
-	}
-
-	if result.Total != expected {
-		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
-	}
+// seqRand is a mock random number generator that returns values from a
+// fixed sequence, starting over when it runs out
+type seqRand struct {
+	values []int
+	i      int
 }
 
-func Test_parseExpression(t *testing.T) {
-	input := "1d6"
-	expected := Die{Sides: 6, Count: 1}
-	result, err := parseExpression(input)
-
-	if err != nil {
-		t.Errorf("parseExpression(%s) = %v; want %v", input, result, expected)
-	}
-
-	if result[0] != expected {
-		t.Errorf("parseExpression(%s) = %v; want %v", input, result, expected)
-	}
+func (r *seqRand) Intn(n int) int {
+	v := r.values[r.i%len(r.values)]
+	r.i++
+	return v
 }
 
-func Test_Complex_parseExpression(t *testing.T) {
-	input := "1d6+2d4+2+1d8+4"
-	expectedDie := []Die{
-		{Sides: 6, Count: 1, Modifier: 0},
-		{Sides: 4, Count: 2, Modifier: 2},
-		{Sides: 8, Count: 1, Modifier: 4},
+func TestDescribeCrits(t *testing.T) {
+	tests := []struct {
+		result   dice.TotalRollResult
+		expected string
+	}{
+		{dice.TotalRollResult{}, ""},
+		{dice.TotalRollResult{CritSuccesses: 1}, "critical success!"},
+		{dice.TotalRollResult{CritFailures: 1}, "critical failure!"},
+		{dice.TotalRollResult{CritSuccesses: 2, CritFailures: 1}, "2 critical successes"},
 	}
-	result, err := parseExpression(input)
-
-	if err != nil {
-		t.Errorf("parseExpression(%s) = %v; want %v", input, result, expectedDie)
-	}
-
-	if len(result) != len(expectedDie) {
-		t.Errorf("parseExpression(%s) = %v; want %v", input, result, expectedDie)
-	}
-
-	for i := range result {
-		if result[i] != expectedDie[i] {
-			t.Errorf("parseExpression(%s) = %v; want %v", input, result, expectedDie)
-			break
+	for _, tt := range tests {
+		if got := describeCrits(tt.result); !strings.Contains(got, tt.expected) {
+			t.Errorf("describeCrits(%+v) = %q; want it to contain %q", tt.result, got, tt.expected)
 		}
 	}
 }
 
-func TestOutOfBoundsReturnsError(t *testing.T) {
-	r := &mockRand{value: 8}
-	input := "1d6"
-	expected := -1
-	result, err := RollDice(r, input)
-
-	if result.Total != expected {
-		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
-		return
-	}
-
-	if err == nil {
-		t.Errorf("RollDice(%s) = %d; want error", input, result.Total)
-		return
+func TestFormatRollsShowsDigits(t *testing.T) {
+	tests := []struct {
+		roll     dice.DieRoll
+		expected string
+	}{
+		{dice.DieRoll{Value: 35, Digits: []int{3, 5}}, "[35(3,5)]"},
+		{dice.DieRoll{Value: 34, Digits: []int{3, 4}, ExtraTens: []int{70}}, "[34(30 ~70~+4)]"},
+		{dice.DieRoll{Value: 100, Digits: []int{0, 0}, ExtraTens: []int{40, 90}}, "[100(00 ~40~ ~90~+0)]"},
 	}
-
-	if err.Error() != "out of bounds somehow! 9 > 6" {
-		t.Errorf("RollDice(%s) = %s; want error", input, err)
-		return
-	}
-}
-
-func TestAddingModifiers(t *testing.T) {
-	r := &mockRand{value: 3}
-	input := "1d6+2"
-	expected := 6
-	result, err := RollDice(r, input)
-
-	if err != nil {
-		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
-	}
-
-	if result.Total != expected {
-		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
-	}
-}
-
-func TestNegativeModifier(t *testing.T) {
-	r := &mockRand{value: 3}
-	input := "1d6-2"
-	expected := 2
-	result, err := RollDice(r, input)
-	if err != nil {
-		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
-	}
-	if result.Total != expected {
-		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
-	}
-}
-
-func TestMissingDieCount(t *testing.T) {
-	r := &mockRand{value: 3}
-	input := "d6"
-	expected := 4
-	result, err := RollDice(r, input)
-	if err != nil {
-		t.Errorf("RollDice(%s) = %d; want %d; error %v", input, result.Total, expected, err)
-	}
-	if result.Total != expected {
-		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
+	for _, tt := range tests {
+		if got := formatRolls([]dice.DieRoll{tt.roll}); got != tt.expected {
+			t.Errorf("formatRolls(%+v) = %q; want %q", tt.roll, got, tt.expected)
+		}
 	}
 }
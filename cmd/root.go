@@ -29,7 +29,12 @@ import (
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+var (
+	cfgFile string
+	seed    int64
+	rngMode string
+	verbose bool
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -57,6 +62,9 @@ func init() {
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.workbench.yaml)")
+	rootCmd.PersistentFlags().Int64Var(&seed, "seed", 0, "seed for the random number generator, to replay a roll")
+	rootCmd.PersistentFlags().StringVar(&rngMode, "rng", rngSeeded, `random number generator: "seeded" or "crypto"`)
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "show extra detail, such as the seed used")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -86,3 +94,21 @@ func initConfig() {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+// dataDir is where workbench keeps files it creates, such as roll history.
+// It defaults to ~/.workbench and can be changed with data_dir in the config.
+// The directory is created if it doesn't exist.
+func dataDir() (string, error) {
+	dir := viper.GetString("data_dir")
+	if dir == "" {
+		dir = "~/.workbench"
+	}
+	dir, err := expandPath(dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("error creating data directory: %w", err)
+	}
+	return dir, nil
+}
@@ -22,12 +22,9 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"encoding/csv"
 	"fmt"
 	"io"
-	"math/rand"
 	"os"
-	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -40,6 +37,7 @@ var tableCmd = &cobra.Command{
 	
 Examples:
   workbench table roll path/to/table.csv
+  workbench table roll encounters.csv --dice 2d6
   cat table.csv | workbench table roll -`,
 }
 
@@ -49,7 +47,35 @@ var tableRollCmd = &cobra.Command{
 	Short: "Roll on a table from a CSV file or stdin",
 	Long: `Roll on a table from a CSV file or stdin.
 	
-If file is "-", read from stdin.`,
+If file is "-", read from stdin.
+
+If a column is headed "roll" or with a dice expression such as "d100", its
+cells are ranges like 1-4, 5 or 96-00 ("00" is 100). The dice in the header,
+or --dice, are rolled and the row whose range holds the result is picked.
+Ranges must not overlap or leave gaps.
+
+A column headed "weight" instead picks rows in proportion to their weights,
+which can be whole numbers or decimals. The weight column isn't printed.
+Without either column every row is equally likely. With --verbose, the
+chance the selected row had of being picked is shown.
+
+Cells can refer to other tables and dice: {{table:weapons}} is replaced with
+a row rolled on weapons.csv and {{roll:2d6}} with what the dice rolled, and
+the rows picked from other tables are expanded in turn. Tables are looked up
+in --library, then table.library in the config, then the directory of the
+table being rolled on.
+
+--count draws several rows at once. Rows can come up more than once unless
+--unique is given. On a table with a range column, the rows after the first
+are then picked using the odds the dice have of landing on each row that's
+left, rather than by rolling again; dice whose odds can't be worked out,
+such as exploding dice, are rerolled until they land on a new row.
+
+Examples:
+  workbench table roll encounters.csv
+  workbench table roll encounters.csv --dice 2d6
+  workbench table roll npcs.csv --library ~/tables
+  workbench table roll treasure.csv --count 3 --unique`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		file := args[0]
@@ -57,8 +83,29 @@ If file is "-", read from stdin.`,
 		if err != nil {
 			return fmt.Errorf("error getting plain flag: %w", err)
 		}
+		diceExpr, err := cmd.Flags().GetString("dice")
+		if err != nil {
+			return fmt.Errorf("error getting dice flag: %w", err)
+		}
+		count, err := cmd.Flags().GetInt("count")
+		if err != nil {
+			return fmt.Errorf("error getting count flag: %w", err)
+		}
+		unique, err := cmd.Flags().GetBool("unique")
+		if err != nil {
+			return fmt.Errorf("error getting unique flag: %w", err)
+		}
+		library, err := cmd.Flags().GetString("library")
+		if err != nil {
+			return fmt.Errorf("error getting library flag: %w", err)
+		}
+		library, err = tableLibrary(library, file)
+		if err != nil {
+			return err
+		}
 
 		var reader io.Reader
+		root := ""
 		if file == "-" {
 			reader = cmd.InOrStdin()
 		} else {
@@ -68,62 +115,103 @@ If file is "-", read from stdin.`,
 			}
 			defer f.Close()
 			reader = f
+			root = file
 		}
 
-		csvReader := csv.NewReader(reader)
-		records, err := csvReader.ReadAll()
+		t, err := readTable(reader)
 		if err != nil {
-			return fmt.Errorf("error reading CSV: %w", err)
+			return err
 		}
 
-		if len(records) == 0 {
-			return fmt.Errorf("CSV file is empty")
+		r, source, err := newRand("table")
+		if err != nil {
+			return err
 		}
-
-		// Skip header row if it exists
-		startRow := 0
-		if len(records) > 1 {
-			startRow = 1
+		// Dice rolled on the table and inside its rows are logged under the
+		// table's name.
+		label := ""
+		if root != "" {
+			label = tableName(root)
+		}
+		logger := newRollLogger(label, source)
+		selections, err := drawRows(r, t, diceExpr, count, unique, logger)
+		if err != nil {
+			return err
+		}
+		res := newTableResolver(r, library, root)
+		res.logger = logger
+		records := make([][]string, len(selections))
+		for i, selected := range selections {
+			if records[i], err = res.expandRow(t.rows[selected.row]); err != nil {
+				return err
+			}
 		}
-
-		// Randomly select a row
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
-		selectedRow := r.Intn(len(records)-startRow) + startRow
-		selectedRecord := records[selectedRow]
 
 		if plain {
 			// Print as comma-separated values
-			for i, field := range selectedRecord {
-				if i > 0 {
-					cmd.Print(",")
+			for _, record := range records {
+				for i, field := range record {
+					if i > 0 {
+						cmd.Print(",")
+					}
+					cmd.Print(field)
 				}
-				cmd.Print(field)
+				cmd.Println()
 			}
-			cmd.Println()
-		} else {
-			// Print formatted output
-			if startRow == 1 {
-				cmd.Println("\nSelected row:")
-				for i, field := range selectedRecord {
-					if i < len(records[0]) {
-						cmd.Printf("%s: %s\n", records[0][i], field)
-					} else {
-						cmd.Printf("Column %d: %s\n", i+1, field)
+			if verbose {
+				for _, selected := range selections {
+					if selected.chance > 0 {
+						cmd.PrintErrf("Chance: %.2f%%\n", selected.chance*100)
 					}
 				}
-			} else {
-				cmd.Println("\nSelected row:")
-				for i, field := range selectedRecord {
-					cmd.Printf("Column %d: %s\n", i+1, field)
+				cmd.PrintErrln(source)
+			}
+		} else {
+			// Print formatted output
+			for i, selected := range selections {
+				heading := "Selected row:"
+				if len(selections) > 1 {
+					heading = fmt.Sprintf("Selected row %d of %d:", i+1, len(selections))
 				}
+				printSelection(cmd, t, selected, heading, records[i])
+			}
+			if verbose {
+				cmd.Printf("\n%s\n", source)
 			}
 		}
 		return nil
 	},
 }
 
+// printSelection prints a selected row, each field labelled with its
+// column's header if the table has one.
+func printSelection(cmd *cobra.Command, t *table, selected tableSelection, heading string, record []string) {
+	if roll := selected.roll; roll != nil {
+		if roll.fromOdds {
+			cmd.Printf("\nPicked from the odds of %s on the rows left: %d\n", roll.expression, roll.total)
+		} else {
+			cmd.Printf("\nRolled %s: %d\n", roll.expression, roll.total)
+		}
+	}
+	cmd.Printf("\n%s\n", heading)
+	for i, field := range record {
+		if i < len(t.header) {
+			cmd.Printf("%s: %s\n", t.header[i], field)
+		} else {
+			cmd.Printf("Column %d: %s\n", i+1, field)
+		}
+	}
+	if verbose && selected.chance > 0 {
+		cmd.Printf("\nChance: %.2f%%\n", selected.chance*100)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(tableCmd)
 	tableCmd.AddCommand(tableRollCmd)
 	tableRollCmd.Flags().BoolP("plain", "p", false, "Enable plain output")
+	tableRollCmd.Flags().StringP("dice", "d", "", "Dice to roll against the table's range column")
+	tableRollCmd.Flags().String("library", "", "Directory to look up {{table:name}} references in")
+	tableRollCmd.Flags().Int("count", 1, "Number of rows to draw")
+	tableRollCmd.Flags().Bool("unique", false, "Don't draw the same row more than once")
 }
@@ -0,0 +1,276 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+)
+
+// maxRerolls is how many times dice whose odds can't be worked out are
+// rolled on a table with a range column looking for a row that hasn't
+// already been drawn.
+const maxRerolls = 1000
+
+// tableRoll is what the dice rolled to pick a row on a table with a range
+// column. fromOdds is set when the total wasn't rolled but picked from the
+// dice's odds of landing on a row that hadn't been drawn yet.
+type tableRoll struct {
+	expression string
+	total      int
+	fromOdds   bool
+}
+
+// tableSelection is a row picked from a table, with the dice rolled to pick
+// it if any and the chance it had of being picked. chance is 0 when it
+// can't be worked out, such as for exploding dice.
+type tableSelection struct {
+	row    int
+	roll   *tableRoll
+	chance float64
+}
+
+// rowPicker picks rows from a table. Tables with a range column are rolled
+// on with their dice, which must be able to reach every row and nothing
+// else. Tables with a weight column pick rows in proportion to their
+// weights. Otherwise every row is equally likely.
+type rowPicker struct {
+	// Tables with a range column.
+	ranges     []tableRange
+	expression string
+	dist       dice.Distribution
+	drawn      map[int]bool
+	// drawnChance is the chance the dice had of rolling a drawn row.
+	drawnChance float64
+
+	// Tables with a weight column.
+	weights []float64
+	total   float64
+	sampler *aliasSampler
+
+	// Other tables: the rows that can still be picked.
+	remaining []int
+
+	// logger records the dice rolled against a range column, if set.
+	logger *rollLogger
+}
+
+func newRowPicker(t *table, diceFlag string) (*rowPicker, error) {
+	col := t.rangeColumn()
+	switch {
+	case col >= 0 && t.weights != nil:
+		return nil, errors.New("a table can have a range column or a weight column, not both")
+	case col >= 0:
+		ranges, err := t.ranges(col)
+		if err != nil {
+			return nil, err
+		}
+		expression, err := t.tableDice(col, ranges, diceFlag)
+		if err != nil {
+			return nil, err
+		}
+		dist, err := checkCoverage(expression, ranges)
+		if err != nil {
+			return nil, err
+		}
+		return &rowPicker{ranges: ranges, expression: expression, dist: dist, drawn: map[int]bool{}}, nil
+	case diceFlag != "":
+		return nil, errors.New(`--dice needs a range column headed "roll" or with the dice to roll, such as "d100"`)
+	case t.weights != nil:
+		// Copied, since drawing without replacement zeroes them.
+		p := &rowPicker{weights: append([]float64{}, t.weights...)}
+		for _, w := range p.weights {
+			p.total += w
+		}
+		var err error
+		p.sampler, err = newAliasSampler(p.weights)
+		return p, err
+	}
+	p := &rowPicker{remaining: make([]int, len(t.rows))}
+	for i := range p.remaining {
+		p.remaining[i] = i
+	}
+	return p, nil
+}
+
+// pick picks a row.
+func (p *rowPicker) pick(r dice.RandIntn) (tableSelection, error) {
+	switch {
+	case p.ranges != nil:
+		return p.pickRange(r)
+	case p.weights != nil:
+		row := p.sampler.sample(r)
+		return tableSelection{row: row, chance: p.weights[row] / p.total}, nil
+	}
+	return tableSelection{row: p.remaining[r.Intn(len(p.remaining))], chance: 1 / float64(len(p.remaining))}, nil
+}
+
+// pickRange rolls the dice, rerolling rows that have already been drawn.
+// When the odds are known, once a row has been drawn the total is picked
+// from the distribution of the rows that are left instead, so rare rows
+// can't make it fail.
+func (p *rowPicker) pickRange(r dice.RandIntn) (tableSelection, error) {
+	if p.dist != nil && len(p.drawn) > 0 {
+		return p.pickUndrawnRange(r)
+	}
+	for range maxRerolls {
+		result, err := rollDice(r, p.expression, nil)
+		if err != nil {
+			return tableSelection{}, err
+		}
+		logRolls(p.logger, result)
+		match, ok := findRange(p.ranges, result.Total)
+		if !ok {
+			return tableSelection{}, fmt.Errorf("%s rolled %d, which no row covers", p.expression, result.Total)
+		}
+		if p.drawn[match.row] {
+			continue
+		}
+		return tableSelection{
+			row:    match.row,
+			roll:   &tableRoll{expression: p.expression, total: result.Total},
+			chance: p.dist.Between(match.lo, match.hi) / (1 - p.drawnChance),
+		}, nil
+	}
+	return tableSelection{}, fmt.Errorf("%s didn't roll a row that hadn't already been drawn in %d tries", p.expression, maxRerolls)
+}
+
+// pickUndrawnRange picks a total from the dice's distribution given that it
+// lands on a row that hasn't been drawn yet.
+func (p *rowPicker) pickUndrawnRange(r dice.RandIntn) (tableSelection, error) {
+	values := p.dist.Values()
+	weights := make([]float64, len(values))
+	for i, v := range values {
+		if rg, ok := findRange(p.ranges, v); ok && !p.drawn[rg.row] {
+			weights[i] = p.dist[v]
+		}
+	}
+	sampler, err := newAliasSampler(weights)
+	if err != nil {
+		return tableSelection{}, err
+	}
+	total := values[sampler.sample(r)]
+	match, _ := findRange(p.ranges, total)
+	return tableSelection{
+		row:    match.row,
+		roll:   &tableRoll{expression: p.expression, total: total, fromOdds: true},
+		chance: p.dist.Between(match.lo, match.hi) / (1 - p.drawnChance),
+	}, nil
+}
+
+// remove stops row from being picked again.
+func (p *rowPicker) remove(row int) error {
+	switch {
+	case p.ranges != nil:
+		p.drawn[row] = true
+		for _, rg := range p.ranges {
+			if rg.row == row {
+				p.drawnChance += p.dist.Between(rg.lo, rg.hi)
+			}
+		}
+		return nil
+	case p.weights != nil:
+		p.total -= p.weights[row]
+		p.weights[row] = 0
+		if p.available() == 0 {
+			return nil
+		}
+		var err error
+		p.sampler, err = newAliasSampler(p.weights)
+		return err
+	}
+	for i, n := range p.remaining {
+		if n == row {
+			p.remaining = append(p.remaining[:i], p.remaining[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// available counts the rows that can still be picked. Rows the dice can't
+// reach aren't counted, unless the odds can't be worked out.
+func (p *rowPicker) available() int {
+	n := 0
+	switch {
+	case p.ranges != nil:
+		for _, rg := range p.ranges {
+			if !p.drawn[rg.row] && (p.dist == nil || p.dist.Between(rg.lo, rg.hi) > 0) {
+				n++
+			}
+		}
+	case p.weights != nil:
+		for _, w := range p.weights {
+			if w > 0 {
+				n++
+			}
+		}
+	default:
+		n = len(p.remaining)
+	}
+	return n
+}
+
+// selectRow picks a row of t, logging any dice rolled with logger.
+func selectRow(r dice.RandIntn, t *table, diceFlag string, logger *rollLogger) (tableSelection, error) {
+	p, err := newRowPicker(t, diceFlag)
+	if err != nil {
+		return tableSelection{}, err
+	}
+	p.logger = logger
+	return p.pick(r)
+}
+
+// drawRows picks count rows of t. Rows can come up more than once unless
+// unique is set, in which case each chance is of the row being picked from
+// those that hadn't been drawn yet. Dice rolled to pick rows are logged with
+// logger.
+func drawRows(r dice.RandIntn, t *table, diceFlag string, count int, unique bool, logger *rollLogger) ([]tableSelection, error) {
+	if count < 1 {
+		return nil, errors.New("--count must be at least 1")
+	}
+	p, err := newRowPicker(t, diceFlag)
+	if err != nil {
+		return nil, err
+	}
+	p.logger = logger
+	if n := p.available(); unique && count > n {
+		return nil, fmt.Errorf("can't draw %d different rows: only %d can come up", count, n)
+	}
+
+	selections := make([]tableSelection, 0, count)
+	for range count {
+		selected, err := p.pick(r)
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selected)
+		if unique {
+			if err := p.remove(selected.row); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return selections, nil
+}
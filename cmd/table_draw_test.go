@@ -0,0 +1,198 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+)
+
+func TestDrawRowsUnique(t *testing.T) {
+	tables := map[string][][]string{
+		"uniform":  {{"Name"}, {"a"}, {"b"}, {"c"}, {"d"}},
+		"weighted": {{"Name", "weight"}, {"a", "1"}, {"b", "100"}, {"c", "0.5"}, {"d", "0"}},
+		"ranged":   {{"d20", "Name"}, {"1-17", "a"}, {"18", "b"}, {"19", "c"}, {"20", "d"}},
+	}
+	counts := map[string]int{"uniform": 4, "weighted": 3, "ranged": 4}
+	for name, records := range tables {
+		for seed := range int64(20) {
+			tbl, err := newTable(records)
+			if err != nil {
+				t.Fatalf("newTable returned error: %v", err)
+			}
+			selections, err := drawRows(dice.NewSeededRand(seed), tbl, "", counts[name], true, nil)
+			if err != nil {
+				t.Fatalf("%s: drawRows returned error: %v", name, err)
+			}
+			seen := map[int]bool{}
+			for _, selected := range selections {
+				if seen[selected.row] {
+					t.Errorf("%s: row %d was drawn twice with seed %d", name, selected.row, seed)
+				}
+				seen[selected.row] = true
+				if tbl.rows[selected.row][len(tbl.rows[selected.row])-1] == "d" && name == "weighted" {
+					t.Errorf("%s: drew a row with a weight of 0", name)
+				}
+			}
+			if last := selections[len(selections)-1]; !almostEqual(last.chance, 1) {
+				t.Errorf("%s: the last row left had a chance of %f; want 1", name, last.chance)
+			}
+		}
+	}
+}
+
+func TestDrawRowsUniqueReachesRareRows(t *testing.T) {
+	// Every result of 4d6 has its own row, so drawing them all means
+	// landing on 4 and 24, which only come up 1 time in 1296.
+	records := [][]string{{"4d6", "Name"}}
+	for v := 4; v <= 24; v++ {
+		records = append(records, []string{strconv.Itoa(v), strconv.Itoa(v)})
+	}
+	tbl, err := newTable(records)
+	if err != nil {
+		t.Fatalf("newTable returned error: %v", err)
+	}
+	for seed := range int64(50) {
+		selections, err := drawRows(dice.NewSeededRand(seed), tbl, "", 21, true, nil)
+		if err != nil {
+			t.Fatalf("drawRows with seed %d returned error: %v", seed, err)
+		}
+		seen := map[int]bool{}
+		for _, selected := range selections {
+			if seen[selected.row] {
+				t.Fatalf("row %d was drawn twice with seed %d", selected.row, seed)
+			}
+			seen[selected.row] = true
+			if got := tbl.rows[selected.row][1]; got != strconv.Itoa(selected.roll.total) {
+				t.Errorf("rolling %d selected row %q", selected.roll.total, got)
+			}
+		}
+	}
+}
+
+func TestDrawRowsWithRepeats(t *testing.T) {
+	tbl, err := newTable([][]string{{"Name"}, {"a"}, {"b"}})
+	if err != nil {
+		t.Fatalf("newTable returned error: %v", err)
+	}
+	selections, err := drawRows(&mockRand{value: 1}, tbl, "", 5, false, nil)
+	if err != nil {
+		t.Fatalf("drawRows returned error: %v", err)
+	}
+	if len(selections) != 5 {
+		t.Fatalf("drew %d rows; want 5", len(selections))
+	}
+	for _, selected := range selections {
+		if selected.row != 1 || !almostEqual(selected.chance, 0.5) {
+			t.Errorf("selection = %+v; want row 1 with a chance of 0.5", selected)
+		}
+	}
+}
+
+func TestDrawRowsLogsRangeRolls(t *testing.T) {
+	tbl, err := newTable([][]string{{"d20", "Name"}, {"1-10", "a"}, {"11-20", "b"}})
+	if err != nil {
+		t.Fatalf("newTable returned error: %v", err)
+	}
+	logger := testLogger(t, "encounters", rngSource{mode: rngSeeded, seed: 1})
+	if _, err := drawRows(&mockRand{value: 4}, tbl, "", 3, false, logger); err != nil {
+		t.Fatalf("drawRows returned error: %v", err)
+	}
+	entries, err := readRollLog(logger.path, rollLogFilter{})
+	if err != nil {
+		t.Fatalf("readRollLog returned error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("logged %d rolls; want 3", len(entries))
+	}
+	for _, e := range entries {
+		if e.Expression != "d20" || e.Label != "encounters" || e.Result.Total != 5 {
+			t.Errorf("logged %+v; want d20 rolling 5 labelled encounters", e)
+		}
+	}
+}
+
+func TestDrawRowsErrors(t *testing.T) {
+	encounters := [][]string{{"d100", "Encounter"}, {"01-15", "Goblins"}, {"16-60", "Wolves"}, {"61-00", "Nothing"}}
+	tests := []struct {
+		records  [][]string
+		dice     string
+		count    int
+		expected string
+	}{
+		{encounters, "", 0, "--count must be at least 1"},
+		{encounters, "", 4, "only 3 can come up"},
+		{encounters, "d20", 3, "only 2 can come up"},
+		{[][]string{{"Name", "weight"}, {"a", "1"}, {"b", "0"}}, "", 2, "only 1 can come up"},
+	}
+	for _, tt := range tests {
+		tbl, err := newTable(tt.records)
+		if err != nil {
+			t.Fatalf("newTable returned error: %v", err)
+		}
+		_, err = drawRows(dice.NewSeededRand(1), tbl, tt.dice, tt.count, true, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("drawRows(%d, %q) error = %v; want it to contain %q", tt.count, tt.dice, err, tt.expected)
+		}
+	}
+}
+
+func TestTableRollCommandWithCount(t *testing.T) {
+	defer tableRollCmd.Flags().Set("count", "1")
+	defer tableRollCmd.Flags().Set("unique", "false")
+	fixture := filepath.Join("..", "testfixtures", "sample.csv")
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"table", "roll", fixture, "--count", "4", "--unique", "--plain"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 rows, got %q", buf.String())
+	}
+	seen := map[string]bool{}
+	for _, line := range lines {
+		if seen[line] {
+			t.Errorf("Row %q was drawn twice", line)
+		}
+		seen[line] = true
+	}
+
+	buf.Reset()
+	rootCmd.SetArgs([]string{"table", "roll", fixture, "--count", "2", "--plain=false"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{"Selected row 1 of 2:", "Selected row 2 of 2:"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected output to contain %q, got %q", expected, buf.String())
+		}
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+)
+
+// table is a CSV table to roll on. header is nil when the table has no
+// header row. weights holds each row's weight when the table has a weight
+// column, which is left out of header and rows.
+type table struct {
+	header  []string
+	rows    [][]string
+	weights []float64
+}
+
+// newTable splits CSV records into a header and rows. The first record is
+// taken as a header whenever there's more than one.
+func newTable(records [][]string) (*table, error) {
+	if len(records) < 2 {
+		return &table{rows: records}, nil
+	}
+	t := &table{header: records[0], rows: records[1:]}
+	col := weightColumn(t.header)
+	if col < 0 {
+		return t, nil
+	}
+	t.header = without(t.header, col)
+	t.weights = make([]float64, len(t.rows))
+	rows := t.rows
+	t.rows = make([][]string, len(rows))
+	for i, row := range rows {
+		if col >= len(row) {
+			return nil, fmt.Errorf("line %d has no weight", t.line(i))
+		}
+		w, err := parseWeight(row[col])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", t.line(i), err)
+		}
+		t.weights[i] = w
+		t.rows[i] = without(row, col)
+	}
+	return t, nil
+}
+
+// without returns a copy of fields with column col removed.
+func without(fields []string, col int) []string {
+	return append(append([]string{}, fields[:col]...), fields[col+1:]...)
+}
+
+// line is the line of the CSV file that row i came from, for errors.
+func (t *table) line(i int) int {
+	if t.header != nil {
+		return i + 2
+	}
+	return i + 1
+}
+
+// tableRange is the span of dice results, from lo to hi, that selects a row.
+type tableRange struct {
+	lo, hi int
+	row    int
+}
+
+// rangeColumn finds the column holding each row's range of dice results:
+// the first one headed "roll" or with a dice expression such as "d100" as
+// its header. It returns -1 if there isn't one.
+func (t *table) rangeColumn() int {
+	for i, name := range t.header {
+		if strings.EqualFold(strings.TrimSpace(name), "roll") || isDiceHeader(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isDiceHeader reports whether a header is a dice expression such as "d100"
+// or "2d6" rather than a column name.
+func isDiceHeader(name string) bool {
+	if !strings.ContainsAny(name, "dD") {
+		return false
+	}
+	e, err := parseExpression(strings.TrimSpace(name), nil)
+	return err == nil && !e.Symbolic()
+}
+
+// parseRange parses a range such as "1-4", "5" or "96-00". Either end can
+// be negative, as in "-4--2" or "-1-1", for dice such as 4dF that can roll
+// below zero.
+func parseRange(s string) (lo, hi int, err error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "–", "-")
+	// The dash between the ends is the first one after the first end's
+	// digits, so skip a leading minus sign when looking for it.
+	sep := -1
+	if len(s) > 1 {
+		if i := strings.Index(s[1:], "-"); i >= 0 {
+			sep = i + 1
+		}
+	}
+	if sep < 0 {
+		lo, err = parseRangeValue(s)
+		return lo, lo, err
+	}
+	if lo, err = parseRangeValue(s[:sep]); err != nil {
+		return 0, 0, err
+	}
+	if hi, err = parseRangeValue(s[sep+1:]); err != nil {
+		return 0, 0, err
+	}
+	if lo > hi {
+		return 0, 0, fmt.Errorf("range %q runs backwards", s)
+	}
+	return lo, hi, nil
+}
+
+// parseRangeValue parses one end of a range. As on percentile tables, "00"
+// stands for 100 (and "000" for 1000).
+func parseRangeValue(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 1 && strings.Trim(s, "0") == "" {
+		return int(math.Pow10(len(s))), nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a range like 1-4 or 5", s)
+	}
+	return n, nil
+}
+
+// ranges parses the range column col of every row, sorted by where they
+// start, and checks that no two overlap and there are no gaps between them.
+func (t *table) ranges(col int) ([]tableRange, error) {
+	ranges := make([]tableRange, 0, len(t.rows))
+	for i, row := range t.rows {
+		if col >= len(row) {
+			return nil, fmt.Errorf("line %d has no range", t.line(i))
+		}
+		lo, hi, err := parseRange(row[col])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", t.line(i), err)
+		}
+		ranges = append(ranges, tableRange{lo: lo, hi: hi, row: i})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].lo < ranges[j].lo })
+	for i := 1; i < len(ranges); i++ {
+		prev, cur := ranges[i-1], ranges[i]
+		switch {
+		case cur.lo <= prev.hi:
+			return nil, fmt.Errorf("lines %d and %d overlap at %d", t.line(prev.row), t.line(cur.row), cur.lo)
+		case cur.lo > prev.hi+1:
+			return nil, fmt.Errorf("no row covers %s", formatSpan(prev.hi+1, cur.lo-1))
+		}
+	}
+	return ranges, nil
+}
+
+func formatSpan(lo, hi int) string {
+	if lo == hi {
+		return strconv.Itoa(lo)
+	}
+	return fmt.Sprintf("%d-%d", lo, hi)
+}
+
+// tableDice works out which dice to roll on a table with a range column:
+// the --dice flag if given, then the column's header, and otherwise a single
+// die as big as the largest range, for tables numbered from 1.
+func (t *table) tableDice(col int, ranges []tableRange, flag string) (string, error) {
+	switch {
+	case flag != "":
+		return flag, nil
+	case isDiceHeader(t.header[col]):
+		return strings.TrimSpace(t.header[col]), nil
+	case ranges[0].lo == 1:
+		return fmt.Sprintf("d%d", ranges[len(ranges)-1].hi), nil
+	}
+	return "", fmt.Errorf("can't tell which dice to roll for ranges starting at %d: use --dice", ranges[0].lo)
+}
+
+// findRange returns the range containing n.
+func findRange(ranges []tableRange, n int) (tableRange, bool) {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].hi >= n })
+	if i < len(ranges) && ranges[i].lo <= n {
+		return ranges[i], true
+	}
+	return tableRange{}, false
+}
+
+// checkCoverage checks that every result the dice can roll selects a row,
+// returning the dice's distribution. Dice whose odds can't be worked out
+// exactly, such as exploding dice, are only checked once they've been rolled
+// and have a nil distribution.
+func checkCoverage(expression string, ranges []tableRange) (dice.Distribution, error) {
+	e, err := parseExpression(expression, nil)
+	if err != nil {
+		return nil, err
+	}
+	if e.Symbolic() {
+		return nil, fmt.Errorf("can't roll symbolic dice %s on a table", expression)
+	}
+	dist, err := e.Distribution()
+	if errors.Is(err, dice.ErrInexact) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := dist.Values()
+	first, last := values[0], values[len(values)-1]
+	if first < ranges[0].lo {
+		return nil, fmt.Errorf("no row covers %s, which %s can roll", formatSpan(first, min(last, ranges[0].lo-1)), expression)
+	}
+	if end := ranges[len(ranges)-1].hi; last > end {
+		return nil, fmt.Errorf("no row covers %s, which %s can roll", formatSpan(max(first, end+1), last), expression)
+	}
+	return dist, nil
+}
@@ -0,0 +1,246 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		input  string
+		lo, hi int
+	}{
+		{"5", 5, 5},
+		{"1-4", 1, 4},
+		{" 01 - 15 ", 1, 15},
+		{"96-00", 96, 100},
+		{"00", 100, 100},
+		{"3–6", 3, 6},
+		{"-2", -2, -2},
+		{"0", 0, 0},
+		{"-4--2", -4, -2},
+		{"-1-1", -1, 1},
+		{" -3 - -1 ", -3, -1},
+	}
+	for _, tt := range tests {
+		lo, hi, err := parseRange(tt.input)
+		if err != nil {
+			t.Errorf("parseRange(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if lo != tt.lo || hi != tt.hi {
+			t.Errorf("parseRange(%q) = %d, %d; want %d, %d", tt.input, lo, hi, tt.lo, tt.hi)
+		}
+	}
+
+	for _, input := range []string{"", "a-b", "6-3", "1-", "-", "--2", "2--4"} {
+		if _, _, err := parseRange(input); err == nil {
+			t.Errorf("parseRange(%q) expected an error", input)
+		}
+	}
+}
+
+func TestTableRangesRejectsGapsAndOverlaps(t *testing.T) {
+	tests := []struct {
+		records  [][]string
+		expected string
+	}{
+		{[][]string{{"roll", "Name"}, {"1-4", "a"}, {"4-6", "b"}}, "lines 2 and 3 overlap at 4"},
+		{[][]string{{"roll", "Name"}, {"1-2", "a"}, {"5-6", "b"}}, "no row covers 3-4"},
+		{[][]string{{"roll", "Name"}, {"1-2", "a"}, {"x", "b"}}, "line 3"},
+	}
+	for _, tt := range tests {
+		tbl, err := newTable(tt.records)
+		if err != nil {
+			t.Fatalf("newTable returned error: %v", err)
+		}
+		_, err = tbl.ranges(tbl.rangeColumn())
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("ranges(%v) error = %v; want it to contain %q", tt.records, err, tt.expected)
+		}
+	}
+}
+
+func TestSelectRow(t *testing.T) {
+	records := [][]string{{"d100", "Encounter"}, {"01-15", "Goblins"}, {"16-60", "Wolves"}, {"61-00", "Nothing"}}
+	tests := []struct {
+		value    int
+		expected string
+	}{
+		{0, "Goblins"},
+		{14, "Goblins"},
+		{15, "Wolves"},
+		{99, "Nothing"},
+	}
+	for _, tt := range tests {
+		tbl, err := newTable(records)
+		if err != nil {
+			t.Fatalf("newTable returned error: %v", err)
+		}
+		selected, err := selectRow(&mockRand{value: tt.value}, tbl, "", nil)
+		if err != nil {
+			t.Fatalf("selectRow returned error: %v", err)
+		}
+		if got := tbl.rows[selected.row][1]; got != tt.expected {
+			t.Errorf("rolling %d selected %q; want %q", tt.value+1, got, tt.expected)
+		}
+		if roll := selected.roll; roll == nil || roll.expression != "d100" || roll.total != tt.value+1 {
+			t.Errorf("roll = %+v; want d100 rolling %d", roll, tt.value+1)
+		}
+		if want := map[string]float64{"Goblins": 0.15, "Wolves": 0.45, "Nothing": 0.4}[tt.expected]; !almostEqual(selected.chance, want) {
+			t.Errorf("chance of %q = %f; want %f", tt.expected, selected.chance, want)
+		}
+	}
+}
+
+func TestSelectRowWithNegativeRanges(t *testing.T) {
+	fate := [][]string{{"4dF", "Reaction"}, {"-4--2", "Hostile"}, {"-1-1", "Wary"}, {"2-4", "Friendly"}}
+	shifted := [][]string{{"roll", "Luck"}, {"-2-0", "Bad"}, {"1-3", "Good"}}
+	tests := []struct {
+		records  [][]string
+		dice     string
+		value    int
+		total    int
+		expected string
+	}{
+		{fate, "", 0, -4, "Hostile"},
+		{fate, "", 1, 0, "Wary"},
+		{fate, "", 2, 4, "Friendly"},
+		{shifted, "1d6-3", 0, -2, "Bad"},
+		{shifted, "1d6-3", 5, 3, "Good"},
+	}
+	for _, tt := range tests {
+		tbl, err := newTable(tt.records)
+		if err != nil {
+			t.Fatalf("newTable returned error: %v", err)
+		}
+		selected, err := selectRow(&mockRand{value: tt.value}, tbl, tt.dice, nil)
+		if err != nil {
+			t.Fatalf("selectRow(%v) returned error: %v", tt.records[0], err)
+		}
+		if got := tbl.rows[selected.row][1]; got != tt.expected || selected.roll.total != tt.total {
+			t.Errorf("rolling %d selected %q; want %q from %d", selected.roll.total, got, tt.expected, tt.total)
+		}
+	}
+
+	// The odds come from the dice, so 4dF's rows are 15, 51 and 15 in 81.
+	tbl, err := newTable(fate)
+	if err != nil {
+		t.Fatalf("newTable returned error: %v", err)
+	}
+	selected, err := selectRow(&mockRand{value: 0}, tbl, "", nil)
+	if err != nil {
+		t.Fatalf("selectRow returned error: %v", err)
+	}
+	if !almostEqual(selected.chance, 15.0/81) {
+		t.Errorf("chance of Hostile = %f; want %f", selected.chance, 15.0/81)
+	}
+}
+
+func TestSelectRowDice(t *testing.T) {
+	roll := [][]string{{"roll", "Weather"}, {"2-4", "Rain"}, {"5-9", "Clouds"}, {"10-12", "Sun"}}
+	tests := []struct {
+		records  [][]string
+		dice     string
+		expected string
+	}{
+		{roll, "2d6", "Sun"},
+		{[][]string{{"roll", "Weather"}, {"1-2", "Rain"}, {"3-6", "Sun"}}, "", "Sun"},
+	}
+	for _, tt := range tests {
+		tbl, err := newTable(tt.records)
+		if err != nil {
+			t.Fatalf("newTable returned error: %v", err)
+		}
+		selected, err := selectRow(&mockRand{value: 5}, tbl, tt.dice, nil)
+		if err != nil {
+			t.Fatalf("selectRow returned error: %v", err)
+		}
+		if got := tbl.rows[selected.row][1]; got != tt.expected {
+			t.Errorf("selectRow selected %q; want %q", got, tt.expected)
+		}
+	}
+
+	errs := []struct {
+		records  [][]string
+		dice     string
+		expected string
+	}{
+		{roll, "", "use --dice"},
+		{roll, "3d6", "no row covers 13-18, which 3d6 can roll"},
+		{roll, "1d6", "no row covers 1, which 1d6 can roll"},
+		{[][]string{{"Name"}, {"a"}, {"b"}}, "d6", "--dice needs a range column"},
+		{[][]string{{"roll", "weight"}, {"1", "1"}, {"2", "1"}}, "", "not both"},
+	}
+	for _, tt := range errs {
+		tbl, err := newTable(tt.records)
+		if err != nil {
+			t.Fatalf("newTable returned error: %v", err)
+		}
+		_, err = selectRow(&mockRand{}, tbl, tt.dice, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("selectRow(%v, %q) error = %v; want it to contain %q", tt.records, tt.dice, err, tt.expected)
+		}
+	}
+}
+
+func TestTableRollCommandWithRanges(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"table", "roll", filepath.Join("..", "testfixtures", "encounters.csv"), "--seed", "1"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output := buf.String()
+	for _, expected := range []string{"Rolled d100:", "Encounter:"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got %q", expected, output)
+		}
+	}
+}
+
+func TestTableRollCommandUniqueRangesSayWhenPicked(t *testing.T) {
+	defer func() {
+		for name, value := range map[string]string{"count": "1", "unique": "false"} {
+			tableRollCmd.Flags().Set(name, value)
+			tableRollCmd.Flags().Lookup(name).Changed = false
+		}
+	}()
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"table", "roll", filepath.Join("..", "testfixtures", "encounters.csv"), "--seed", "1", "--count", "2", "--unique"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output := buf.String()
+	if strings.Count(output, "Rolled d100:") != 1 || strings.Count(output, "Picked from the odds of d100 on the rows left:") != 1 {
+		t.Errorf("Expected the first row to be rolled and the second picked, got %q", output)
+	}
+}
@@ -0,0 +1,214 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+	"github.com/spf13/viper"
+)
+
+// tableLibraryKey is the config key for the directory tables are looked up
+// in by {{table:name}}.
+const tableLibraryKey = "table.library"
+
+// tableNamePattern is what a table can be called in {{table:name}}: letters,
+// digits, underscores and hyphens, so references can't reach outside the
+// library.
+var tableNamePattern = regexp.MustCompile(`^[\w-]+$`)
+
+// referencePattern matches a {{table:name}} or {{roll:expression}} reference
+// in a cell.
+var referencePattern = regexp.MustCompile(`\{\{\s*(\w+)\s*:\s*(.*?)\s*\}\}`)
+
+// readTable reads a CSV table.
+func readTable(reader io.Reader) (*table, error) {
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("CSV file is empty")
+	}
+	return newTable(records)
+}
+
+// tableLibrary is the directory that {{table:name}} looks for name.csv in:
+// --library, then table.library in the config, then the directory of the
+// table being rolled on.
+func tableLibrary(flag, file string) (string, error) {
+	dir := flag
+	if dir == "" {
+		dir = viper.GetString(tableLibraryKey)
+	}
+	if dir != "" {
+		return expandPath(dir)
+	}
+	if file == "-" {
+		return ".", nil
+	}
+	return filepath.Dir(file), nil
+}
+
+// tableResolver expands the references in rolled cells. {{roll:2d6}} is
+// replaced with what the dice rolled, and {{table:name}} with a row rolled
+// on name.csv in the library directory, whose own references are expanded
+// in turn.
+type tableResolver struct {
+	r       dice.RandIntn
+	library string
+	tables  map[string]*table
+	// expanding is the chain of tables being expanded, to catch tables
+	// that refer back to themselves.
+	expanding []string
+	// logger records the dice rolled by {{roll:...}} references and against
+	// range columns, if set.
+	logger *rollLogger
+}
+
+// newTableResolver returns a resolver that looks tables up in library.
+// root is the table being rolled on, if it's a file, so that it can't be
+// referred to from the tables it refers to when it's in the library too.
+func newTableResolver(r dice.RandIntn, library, root string) *tableResolver {
+	res := &tableResolver{r: r, library: library, tables: map[string]*table{}}
+	if root != "" && sameDir(filepath.Dir(root), library) {
+		res.expanding = []string{tableName(root)}
+	}
+	return res
+}
+
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// tableName is the name a table file is referred to by.
+func tableName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// expandRow expands every cell of a row.
+func (res *tableResolver) expandRow(fields []string) ([]string, error) {
+	expanded := make([]string, len(fields))
+	for i, field := range fields {
+		var err error
+		if expanded[i], err = res.expand(field); err != nil {
+			return nil, err
+		}
+	}
+	return expanded, nil
+}
+
+// expand replaces the references in text.
+func (res *tableResolver) expand(text string) (string, error) {
+	var err error
+	expanded := referencePattern.ReplaceAllStringFunc(text, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		m := referencePattern.FindStringSubmatch(ref)
+		var s string
+		switch strings.ToLower(m[1]) {
+		case "roll":
+			s, err = res.roll(m[2])
+		case "table":
+			s, err = res.rollTable(m[2])
+		default:
+			err = fmt.Errorf("unknown reference %q: use {{table:name}} or {{roll:dice}}", ref)
+		}
+		return s
+	})
+	return expanded, err
+}
+
+func (res *tableResolver) roll(expression string) (string, error) {
+	result, err := rollDice(res.r, expression, nil)
+	if err != nil {
+		return "", err
+	}
+	logRolls(res.logger, result)
+	if result.Mode == dice.ModeSymbols {
+		return dice.FormatTally(result.Tally), nil
+	}
+	return strconv.Itoa(result.Total), nil
+}
+
+// rollTable rolls on the named table and expands the row it picks. Every
+// column but the range column is used, separated by commas.
+func (res *tableResolver) rollTable(name string) (string, error) {
+	if !tableNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid table name %q: use only letters, digits, _ and -", name)
+	}
+	for i, n := range res.expanding {
+		if n == name {
+			chain := append(append([]string{}, res.expanding[i:]...), name)
+			return "", fmt.Errorf("tables refer to each other in a loop: %s", strings.Join(chain, " -> "))
+		}
+	}
+	t, err := res.load(name)
+	if err != nil {
+		return "", err
+	}
+	selected, err := selectRow(res.r, t, "", res.logger)
+	if err != nil {
+		return "", fmt.Errorf("table %s: %w", name, err)
+	}
+	fields := t.rows[selected.row]
+	if col := t.rangeColumn(); col >= 0 {
+		fields = without(fields, col)
+	}
+
+	res.expanding = append(res.expanding, name)
+	defer func() { res.expanding = res.expanding[:len(res.expanding)-1] }()
+	fields, err = res.expandRow(fields)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(fields, ", "), nil
+}
+
+// load reads name.csv from the library, once.
+func (res *tableResolver) load(name string) (*table, error) {
+	if t, ok := res.tables[name]; ok {
+		return t, nil
+	}
+	f, err := os.Open(filepath.Join(res.library, name+".csv"))
+	if err != nil {
+		return nil, fmt.Errorf("error opening table %s: %w", name, err)
+	}
+	defer f.Close()
+	t, err := readTable(f)
+	if err != nil {
+		return nil, fmt.Errorf("table %s: %w", name, err)
+	}
+	res.tables[name] = t
+	return t, nil
+}
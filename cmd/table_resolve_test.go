@@ -0,0 +1,116 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTableResolverExpand(t *testing.T) {
+	library := filepath.Join("..", "testfixtures", "library")
+	tests := []struct {
+		text     string
+		values   []int
+		expected string
+	}{
+		{"no references", []int{0}, "no references"},
+		{"{{roll:2d6}} bandits", []int{2}, "6 bandits"},
+		{"{{ roll : 1d4+1 }} goblins", []int{0}, "2 goblins"},
+		{"{{table:npc_names}} wielding {{table:weapons}}", []int{1, 0}, "Mira wielding a rusty sword"},
+		// A 6 on the weapons table refers on to the weighted magic_weapons.
+		{"{{table:weapons}}", []int{5, 0, 0}, "a flaming axe"},
+	}
+	for _, tt := range tests {
+		res := newTableResolver(&seqRand{values: tt.values}, library, "")
+		got, err := res.expand(tt.text)
+		if err != nil {
+			t.Errorf("expand(%q) returned error: %v", tt.text, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("expand(%q) = %q; want %q", tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestTableResolverLogsInlineRolls(t *testing.T) {
+	res := newTableResolver(&seqRand{values: []int{2, 3}}, filepath.Join("..", "testfixtures", "library"), "")
+	res.logger = testLogger(t, "encounters", rngSource{mode: rngSeeded, seed: 5})
+	if _, err := res.expand("{{roll:2d6}} bandits and no dice"); err != nil {
+		t.Fatalf("expand returned error: %v", err)
+	}
+	entries, err := readRollLog(res.logger.path, rollLogFilter{})
+	if err != nil {
+		t.Fatalf("readRollLog returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Expression != "2d6" || entries[0].Label != "encounters" || entries[0].Result.Total != 7 {
+		t.Errorf("roll log = %+v; want the 2d6 roll labelled encounters", entries)
+	}
+}
+
+func TestTableResolverErrors(t *testing.T) {
+	library := filepath.Join("..", "testfixtures", "library")
+	tests := []struct {
+		root     string
+		text     string
+		expected string
+	}{
+		{"", "{{table:loop_a}}", "loop: loop_a -> loop_b -> loop_a"},
+		{filepath.Join(library, "loop_a.csv"), "{{table:loop_b}}", "loop: loop_a -> loop_b -> loop_a"},
+		{"", "{{table:missing}}", "error opening table missing"},
+		{"", "{{roll:2d}}", ""},
+		{"", "{{dice:2d6}}", "unknown reference"},
+		{"", "{{table:../encounters}}", "invalid table name"},
+		{"", "{{table:" + filepath.Join("..", "..", "testfixtures", "encounters") + "}}", "invalid table name"},
+		{"", "{{table:/etc/passwd}}", "invalid table name"},
+	}
+	for _, tt := range tests {
+		res := newTableResolver(&mockRand{}, library, tt.root)
+		_, err := res.expand(tt.text)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("expand(%q) error = %v; want it to contain %q", tt.text, err, tt.expected)
+		}
+	}
+}
+
+func TestTableLibrary(t *testing.T) {
+	tests := []struct {
+		flag, file string
+		expected   string
+	}{
+		{"", filepath.Join("tables", "npcs.csv"), "tables"},
+		{"", "-", "."},
+		{"lib", filepath.Join("tables", "npcs.csv"), "lib"},
+	}
+	for _, tt := range tests {
+		got, err := tableLibrary(tt.flag, tt.file)
+		if err != nil {
+			t.Errorf("tableLibrary(%q, %q) returned error: %v", tt.flag, tt.file, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("tableLibrary(%q, %q) = %q; want %q", tt.flag, tt.file, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+)
+
+// coinPrecision is how finely aliasSampler splits each column between a row
+// and its alias. It has to fit in an int on 32-bit platforms.
+const coinPrecision = 1 << 30
+
+// weightColumn finds the column headed "weight", or returns -1.
+func weightColumn(header []string) int {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), "weight") {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseWeight parses a row's weight, which can be a whole number or a
+// decimal but not negative.
+func parseWeight(s string) (float64, error) {
+	w, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+		return 0, fmt.Errorf("weight %q must be a number of 0 or more", s)
+	}
+	return w, nil
+}
+
+// aliasSampler picks indexes in proportion to their weights in constant time
+// using Vose's alias method, so big weighted tables are as quick to roll on
+// as small ones. Each index owns a column that's split between itself and
+// an alias: a column is picked uniformly, then a biased coin chooses which of
+// the two to return.
+type aliasSampler struct {
+	prob  []float64
+	alias []int
+}
+
+func newAliasSampler(weights []float64) (*aliasSampler, error) {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return nil, errors.New("every row has a weight of 0")
+	}
+
+	n := len(weights)
+	s := &aliasSampler{prob: make([]float64, n), alias: make([]int, n)}
+	scaled := make([]float64, n)
+	var small, large []int
+	for i, w := range weights {
+		scaled[i] = w * float64(n) / total
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		l := small[len(small)-1]
+		small = small[:len(small)-1]
+		g := large[len(large)-1]
+		large = large[:len(large)-1]
+
+		s.prob[l] = scaled[l]
+		s.alias[l] = g
+		scaled[g] += scaled[l] - 1
+		if scaled[g] < 1 {
+			small = append(small, g)
+		} else {
+			large = append(large, g)
+		}
+	}
+	// Whatever's left fills its own column, give or take rounding error.
+	for _, i := range append(small, large...) {
+		s.prob[i] = 1
+		s.alias[i] = i
+	}
+	return s, nil
+}
+
+func (s *aliasSampler) sample(r dice.RandIntn) int {
+	i := r.Intn(len(s.prob))
+	if float64(r.Intn(coinPrecision))/coinPrecision < s.prob[i] {
+		return i
+	}
+	return s.alias[i]
+}
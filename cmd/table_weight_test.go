@@ -0,0 +1,116 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+)
+
+func TestNewTableWithWeights(t *testing.T) {
+	tbl, err := newTable([][]string{{"Name", "Weight", "Note"}, {"a", "3", "x"}, {"b", "0.5", "y"}})
+	if err != nil {
+		t.Fatalf("newTable returned error: %v", err)
+	}
+	if strings.Join(tbl.header, ",") != "Name,Note" {
+		t.Errorf("header = %v; want the weight column left out", tbl.header)
+	}
+	if strings.Join(tbl.rows[1], ",") != "b,y" {
+		t.Errorf("rows[1] = %v; want the weight column left out", tbl.rows[1])
+	}
+	if tbl.weights[0] != 3 || tbl.weights[1] != 0.5 {
+		t.Errorf("weights = %v; want [3 0.5]", tbl.weights)
+	}
+
+	for _, records := range [][][]string{
+		{{"Name", "weight"}, {"a", "-1"}},
+		{{"Name", "weight"}, {"a", "lots"}},
+		{{"Name", "weight"}, {"a"}},
+	} {
+		if _, err := newTable(records); err == nil {
+			t.Errorf("newTable(%v) expected an error", records)
+		}
+	}
+}
+
+func TestAliasSampler(t *testing.T) {
+	weights := []float64{10, 4.5, 0.5, 0, 5}
+	s, err := newAliasSampler(weights)
+	if err != nil {
+		t.Fatalf("newAliasSampler returned error: %v", err)
+	}
+
+	// Each column's share of the probability, plus what it passes on to its
+	// alias, must add back up to the weights.
+	got := make([]float64, len(weights))
+	for i := range s.prob {
+		got[i] += s.prob[i] / float64(len(weights))
+		got[s.alias[i]] += (1 - s.prob[i]) / float64(len(weights))
+	}
+	for i, w := range weights {
+		if !almostEqual(got[i], w/20) {
+			t.Errorf("index %d has probability %f; want %f", i, got[i], w/20)
+		}
+	}
+
+	r := dice.NewSeededRand(1)
+	counts := make([]int, len(weights))
+	const samples = 100000
+	for range samples {
+		counts[s.sample(r)]++
+	}
+	if counts[3] != 0 {
+		t.Errorf("index with weight 0 was picked %d times", counts[3])
+	}
+	for i, w := range weights {
+		if got := float64(counts[i]) / samples; got < w/20-0.01 || got > w/20+0.01 {
+			t.Errorf("index %d was picked %.3f of the time; want about %.3f", i, got, w/20)
+		}
+	}
+
+	if _, err := newAliasSampler([]float64{0, 0}); err == nil {
+		t.Error("Expected an error when every weight is 0")
+	}
+}
+
+func TestTableRollCommandWithWeights(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"table", "roll", filepath.Join("..", "testfixtures", "treasure.csv"), "--seed", "1", "--verbose", "--plain=false"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rootCmd.PersistentFlags().Set("verbose", "false")
+
+	output := buf.String()
+	if !strings.Contains(output, "Treasure:") || !strings.Contains(output, "Chance:") {
+		t.Errorf("Expected the selected row and its chance, got %q", output)
+	}
+	if strings.Contains(output, "weight") {
+		t.Errorf("Expected the weight column to be left out, got %q", output)
+	}
+}
@@ -0,0 +1,686 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// RandIntn is the source of randomness for a roll. Intn returns a uniformly
+// distributed value in [0, n). *math/rand.Rand satisfies it, as do
+// SeededRand and CryptoRand.
+type RandIntn interface {
+	Intn(n int) int
+}
+
+// Die is a dice term such as 4d6kh3: how many dice are rolled, their faces
+// and every modifier applied to them.
+type Die struct {
+	Sides int `json:"sides" yaml:"sides"`
+	Count int `json:"count" yaml:"count"`
+	// Faces is set for dice that aren't numbered 1 to Sides, such as Fate
+	// dice or d{hit,miss,crit}. Sides is always the number of faces.
+	Faces   *FaceSet    `json:"faces,omitempty" yaml:"faces,omitempty"`
+	Keep    KeepMode    `json:"keep,omitempty" yaml:"keep,omitempty"`
+	KeepN   int         `json:"keep_n,omitempty" yaml:"keep_n,omitempty"`
+	Explode ExplodeMode `json:"explode,omitempty" yaml:"explode,omitempty"`
+	// ExplodeOn overrides which rolls explode. The zero value explodes on
+	// the highest face.
+	ExplodeOn Compare    `json:"explode_on,omitzero" yaml:"explode_on,omitempty"`
+	Reroll    RerollMode `json:"reroll,omitempty" yaml:"reroll,omitempty"`
+	RerollOn  Compare    `json:"reroll_on,omitzero" yaml:"reroll_on,omitempty"`
+	// Min raises any roll below it up to Min. It's nil when there's no
+	// minimum, since faces can be 0 or negative.
+	Min *int `json:"min,omitempty" yaml:"min,omitempty"`
+	// Bonus and Penalty roll extra tens dice for a d100 and keep the lowest
+	// or highest result, as in Call of Cthulhu.
+	Bonus   int `json:"bonus,omitempty" yaml:"bonus,omitempty"`
+	Penalty int `json:"penalty,omitempty" yaml:"penalty,omitempty"`
+	// SuccessOn turns the dice into a pool that counts the dice matching it
+	// instead of summing them. FailOn counts failures within that pool.
+	SuccessOn Compare `json:"success_on,omitzero" yaml:"success_on,omitempty"`
+	FailOn    Compare `json:"fail_on,omitzero" yaml:"fail_on,omitempty"`
+	// CritSuccessOn and CritFailOn mark kept dice whose natural roll matches
+	// them as critical successes or failures.
+	CritSuccessOn Compare `json:"crit_success_on,omitzero" yaml:"crit_success_on,omitempty"`
+	CritFailOn    Compare `json:"crit_fail_on,omitzero" yaml:"crit_fail_on,omitempty"`
+}
+
+func (d Die) countsSuccesses() bool {
+	return d.SuccessOn.Op != ""
+}
+
+func (d Die) symbolic() bool {
+	return d.Faces != nil && d.Faces.symbolic()
+}
+
+// faceValue is the value of the nth face of the die, counting from 1.
+func (d Die) faceValue(n int) int {
+	if d.Faces == nil || d.Faces.symbolic() {
+		return n
+	}
+	return d.Faces.Values[n-1]
+}
+
+// faceValues lists the value of every face of the die.
+func (d Die) faceValues() []int {
+	values := make([]int, d.Sides)
+	for i := range values {
+		values[i] = d.faceValue(i + 1)
+	}
+	return values
+}
+
+// customValues reports whether the die's faces have values of their own
+// rather than running from 1 to Sides.
+func (d Die) customValues() bool {
+	return d.Faces != nil && !d.Faces.symbolic()
+}
+
+func (d Die) highestFace() int {
+	if d.customValues() {
+		return slices.Max(d.Faces.Values)
+	}
+	return d.Sides
+}
+
+func (d Die) lowestFace() int {
+	if d.customValues() {
+		return slices.Min(d.Faces.Values)
+	}
+	return 1
+}
+
+// matchesEveryFace reports whether c matches every face of the die. Faces
+// from 1 to Sides only need checking at each end, since every condition
+// that holds at both ends holds in between.
+func (d Die) matchesEveryFace(c Compare) bool {
+	if !d.customValues() {
+		return c.Match(1) && c.Match(d.Sides)
+	}
+	for _, v := range d.Faces.Values {
+		if !c.Match(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// ResultMode says whether a roll's Total is a sum of dice or a count of
+// successes.
+type ResultMode int
+
+const (
+	// ModeSum adds up the dice.
+	ModeSum ResultMode = iota
+	// ModeSuccesses counts the dice that meet a success condition.
+	ModeSuccesses
+	// ModeSymbols tallies the faces of symbolic dice.
+	ModeSymbols
+)
+
+var resultModeNames = map[ResultMode]string{
+	ModeSum:       "summed dice",
+	ModeSuccesses: "success pools",
+	ModeSymbols:   "symbolic dice",
+}
+
+// resultModeKeys name each mode in structured output.
+var resultModeKeys = map[ResultMode]string{
+	ModeSum:       "sum",
+	ModeSuccesses: "successes",
+	ModeSymbols:   "symbols",
+}
+
+// maxRerolls caps how many times a single die can be rerolled.
+const maxRerolls = 100
+
+// RerollMode controls how often a die matching its reroll condition is
+// rolled again.
+type RerollMode int
+
+const (
+	RerollNone RerollMode = iota
+	// RerollUntil keeps rerolling until the die no longer matches.
+	RerollUntil
+	// RerollOnce rerolls a matching die a single time and keeps the result.
+	RerollOnce
+)
+
+var rerollModeNames = map[RerollMode]string{
+	RerollUntil: "r",
+	RerollOnce:  "ro",
+}
+
+// maxExplosions caps how many follow-up rolls a single die can trigger.
+const maxExplosions = 100
+
+// ExplodeMode controls what happens when a die rolls its explode condition.
+type ExplodeMode int
+
+const (
+	ExplodeNone ExplodeMode = iota
+	// ExplodeStandard rolls an extra die and adds it to the pool.
+	ExplodeStandard
+	// ExplodeCompound adds the extra rolls into the die that exploded.
+	ExplodeCompound
+	// ExplodePenetrate rolls an extra die with 1 subtracted from it.
+	ExplodePenetrate
+)
+
+var explodeModeNames = map[ExplodeMode]string{
+	ExplodeStandard:  "!",
+	ExplodeCompound:  "!!",
+	ExplodePenetrate: "!p",
+}
+
+// Compare is a condition such as ">=9" tested against a single die.
+type Compare struct {
+	Op    string
+	Value int
+}
+
+// Match reports whether v meets the condition. The zero Compare matches
+// nothing.
+func (c Compare) Match(v int) bool {
+	switch c.Op {
+	case ">=":
+		return v >= c.Value
+	case "<=":
+		return v <= c.Value
+	case ">":
+		return v > c.Value
+	case "<":
+		return v < c.Value
+	case "=":
+		return v == c.Value
+	}
+	return false
+}
+
+// String formats the comparison the way it's written in an expression, where
+// a bare number means "=".
+func (c Compare) String() string {
+	if c.Op == "=" {
+		return strconv.Itoa(c.Value)
+	}
+	return fmt.Sprintf("%s%d", c.Op, c.Value)
+}
+
+func (d Die) explodes(roll int) bool {
+	if d.Explode == ExplodeNone {
+		return false
+	}
+	if d.ExplodeOn.Op == "" {
+		return roll == d.highestFace()
+	}
+	return d.ExplodeOn.Match(roll)
+}
+
+func (d Die) explodesOnEveryFace() bool {
+	if d.Explode == ExplodeNone {
+		return false
+	}
+	if d.ExplodeOn.Op == "" {
+		return d.lowestFace() == d.highestFace()
+	}
+	return d.matchesEveryFace(d.ExplodeOn)
+}
+
+// rerolls reports whether a die that has already been rerolled n times
+// should be rolled again.
+func (d Die) rerolls(roll int, n int) bool {
+	switch d.Reroll {
+	case RerollUntil:
+		return d.RerollOn.Match(roll)
+	case RerollOnce:
+		return n == 0 && d.RerollOn.Match(roll)
+	}
+	return false
+}
+
+func (d Die) rerollsEveryFace() bool {
+	return d.matchesEveryFace(d.RerollOn)
+}
+
+// KeepMode selects which dice of a roll count towards its total.
+type KeepMode int
+
+const (
+	// KeepAll counts every die.
+	KeepAll KeepMode = iota
+	KeepHighest
+	KeepLowest
+	DropHighest
+	DropLowest
+)
+
+var keepModeNames = map[KeepMode]string{
+	KeepHighest: "kh",
+	KeepLowest:  "kl",
+	DropHighest: "dh",
+	DropLowest:  "dl",
+}
+
+// String writes the die in dice notation, e.g. "4d6kh3".
+func (d Die) String() string {
+	s := fmt.Sprintf("%dd%d", d.Count, d.Sides)
+	if d.Faces != nil {
+		s = fmt.Sprintf("%dd%s", d.Count, d.Faces)
+	}
+	if d.Bonus > 0 {
+		s += fmt.Sprintf("b%d", d.Bonus)
+	}
+	if d.Penalty > 0 {
+		s += fmt.Sprintf("p%d", d.Penalty)
+	}
+	if d.Explode != ExplodeNone {
+		s += explodeModeNames[d.Explode]
+		if d.ExplodeOn.Op != "" {
+			s += d.ExplodeOn.String()
+		}
+	}
+	if d.Reroll != RerollNone {
+		s += rerollModeNames[d.Reroll] + d.RerollOn.String()
+	}
+	if d.Min != nil {
+		s += fmt.Sprintf("min%d", *d.Min)
+	}
+	if d.Keep != KeepAll {
+		s += fmt.Sprintf("%s%d", keepModeNames[d.Keep], d.KeepN)
+	}
+	if d.countsSuccesses() {
+		s += fmt.Sprintf("%s%d", d.SuccessOn.Op, d.SuccessOn.Value)
+	}
+	if d.FailOn.Op != "" {
+		s += "f" + d.FailOn.String()
+	}
+	if d.CritSuccessOn.Op != "" {
+		s += "cs" + d.CritSuccessOn.String()
+	}
+	if d.CritFailOn.Op != "" {
+		s += "cf" + d.CritFailOn.String()
+	}
+	return s
+}
+
+// DieRoll is a single die within a RollResult. Dropped dice were rolled but
+// discarded by a keep or drop modifier and don't count towards the total.
+// Exploded dice triggered another roll; for compounding dice the individual
+// rolls that were added together are kept in Chain. Rerolls holds any earlier
+// rolls of the die that were thrown away by a reroll modifier, and Natural is
+// the face that was kept before a minimum was applied. Symbolic dice have a
+// Face instead of a Value. CritSuccess and CritFailure are set when a kept
+// die's natural roll matches the die's crit rules.
+//
+// Digits are the dice that were read positionally to make up the natural
+// roll, most significant first: each d6 of a d66, or the tens and ones of a
+// d100 rolled with bonus or penalty dice. ExtraTens are the tens dice of
+// such a d100 that weren't used, as multiples of 10.
+type DieRoll struct {
+	Value    int    `json:"value" yaml:"value"`
+	Face     string `json:"face,omitempty" yaml:"face,omitempty"`
+	Natural  int    `json:"natural" yaml:"natural"`
+	Dropped  bool   `json:"dropped,omitempty" yaml:"dropped,omitempty"`
+	Exploded bool   `json:"exploded,omitempty" yaml:"exploded,omitempty"`
+	Floored  bool   `json:"floored,omitempty" yaml:"floored,omitempty"`
+	Success  bool   `json:"success,omitempty" yaml:"success,omitempty"`
+	Failure  bool   `json:"failure,omitempty" yaml:"failure,omitempty"`
+	Chain    []int  `json:"chain,omitempty" yaml:"chain,omitempty"`
+	Rerolls  []int  `json:"rerolls,omitempty" yaml:"rerolls,omitempty"`
+
+	Digits    []int `json:"digits,omitempty" yaml:"digits,omitempty"`
+	ExtraTens []int `json:"extra_tens,omitempty" yaml:"extra_tens,omitempty"`
+
+	CritSuccess bool `json:"crit_success,omitempty" yaml:"crit_success,omitempty"`
+	CritFailure bool `json:"crit_failure,omitempty" yaml:"crit_failure,omitempty"`
+}
+
+// RollResult is the outcome of a single dice term. In ModeSuccesses, Total
+// is the number of successes rather than the sum of the dice; in
+// ModeSymbols, Total is unused and the faces are counted in Tally. RolledDie
+// is Die written out in dice notation.
+type RollResult struct {
+	Total     int         `json:"total" yaml:"total"`
+	RolledDie string      `json:"notation" yaml:"notation"`
+	Die       Die         `json:"die" yaml:"die"`
+	Rolls     []DieRoll   `json:"rolls" yaml:"rolls"`
+	Mode      ResultMode  `json:"mode" yaml:"mode"`
+	Successes int         `json:"successes,omitempty" yaml:"successes,omitempty"`
+	Failures  int         `json:"failures,omitempty" yaml:"failures,omitempty"`
+	Tally     []FaceCount `json:"tally,omitempty" yaml:"tally,omitempty"`
+
+	CritSuccesses int `json:"crit_successes,omitempty" yaml:"crit_successes,omitempty"`
+	CritFailures  int `json:"crit_failures,omitempty" yaml:"crit_failures,omitempty"`
+}
+
+// TotalRollResult is the outcome of a whole expression. When the expression
+// rolls a success pool, Mode is ModeSuccesses, Total and Successes are the
+// number of successes after any arithmetic in the expression, and Botch is
+// set if there were failures but no successes. Symbolic dice are reported in
+// Tally with Mode set to ModeSymbols.
+type TotalRollResult struct {
+	Expression string `json:"expression" yaml:"expression"`
+	Total      int    `json:"total" yaml:"total"`
+	// Breakdown is the expression with each dice term replaced by what it
+	// rolled, e.g. "(7 + 3) * 2".
+	Breakdown string       `json:"breakdown" yaml:"breakdown"`
+	Results   []RollResult `json:"results" yaml:"results"`
+	Mode      ResultMode   `json:"mode" yaml:"mode"`
+	Successes int          `json:"successes,omitempty" yaml:"successes,omitempty"`
+	Failures  int          `json:"failures,omitempty" yaml:"failures,omitempty"`
+	Botch     bool         `json:"botch,omitempty" yaml:"botch,omitempty"`
+	Tally     []FaceCount  `json:"tally,omitempty" yaml:"tally,omitempty"`
+	// CritSuccesses and CritFailures count the critical dice across every
+	// term.
+	CritSuccesses int `json:"crit_successes,omitempty" yaml:"crit_successes,omitempty"`
+	CritFailures  int `json:"crit_failures,omitempty" yaml:"crit_failures,omitempty"`
+}
+
+// RollDice parses and rolls a dice expression. Any $variables in it are
+// looked up in vars. Expressions that use macros, or are rolled more than
+// once, should be parsed with Parse instead.
+func RollDice(r RandIntn, expression string, vars Vars) (TotalRollResult, error) {
+	e, err := Parse(expression, Options{Vars: vars})
+	if err != nil {
+		return TotalRollResult{Total: -1}, err
+	}
+	return e.Roll(r)
+}
+
+// rollTree evaluates an already parsed expression.
+func rollTree(r RandIntn, tree exprNode) (TotalRollResult, error) {
+	e := &evaluator{r: r}
+	total, breakdown, err := tree.eval(e)
+	if err != nil {
+		return TotalRollResult{Total: -1}, err
+	}
+	result := TotalRollResult{Total: total, Breakdown: breakdown, Results: e.results}
+
+	if len(e.results) > 0 {
+		result.Mode = e.results[0].Mode
+	}
+	for _, r := range e.results {
+		if r.Mode != result.Mode {
+			return TotalRollResult{Total: -1}, fmt.Errorf("can't mix %s and %s in one expression", resultModeNames[result.Mode], resultModeNames[r.Mode])
+		}
+		result.Failures += r.Failures
+		result.Tally = MergeTally(result.Tally, r.Tally)
+		result.CritSuccesses += r.CritSuccesses
+		result.CritFailures += r.CritFailures
+	}
+	if result.Mode == ModeSuccesses {
+		result.Successes = total
+		result.Botch = total <= 0 && result.Failures > 0
+	}
+	return result, nil
+}
+
+func generateResult(die *Die, r RandIntn) (RollResult, error) {
+	rolls := make([]DieRoll, 0, die.Count)
+	for i := 0; i < die.Count; i++ {
+		chain, err := rollChain(die, r)
+		if err != nil {
+			return RollResult{}, err
+		}
+		rolls = append(rolls, chain...)
+	}
+	applyKeep(die, rolls)
+	var result RollResult
+	switch {
+	case die.countsSuccesses():
+		result = countSuccesses(die, rolls)
+	case die.symbolic():
+		result = RollResult{RolledDie: die.String(), Die: *die, Rolls: rolls, Mode: ModeSymbols, Tally: tallyFaces(die.Faces, rolls)}
+	default:
+		var diceTotal int
+		for _, roll := range rolls {
+			if !roll.Dropped {
+				diceTotal += roll.Value
+			}
+		}
+		result = RollResult{Total: diceTotal, RolledDie: die.String(), Die: *die, Rolls: rolls}
+	}
+	markCrits(die, &result)
+	return result, nil
+}
+
+// markCrits flags the kept dice whose natural roll matches the die's crit
+// rules. A die matching both is a critical success.
+func markCrits(die *Die, result *RollResult) {
+	for i := range result.Rolls {
+		roll := &result.Rolls[i]
+		if roll.Dropped {
+			continue
+		}
+		switch {
+		case die.CritSuccessOn.Match(roll.Natural):
+			roll.CritSuccess = true
+			result.CritSuccesses++
+		case die.CritFailOn.Match(roll.Natural):
+			roll.CritFailure = true
+			result.CritFailures++
+		}
+	}
+}
+
+// countSuccesses tallies the kept dice of a success pool. A die can't be
+// both a success and a failure; if both conditions match, it's a success.
+func countSuccesses(die *Die, rolls []DieRoll) RollResult {
+	result := RollResult{RolledDie: die.String(), Die: *die, Rolls: rolls, Mode: ModeSuccesses}
+	for i := range rolls {
+		if rolls[i].Dropped {
+			continue
+		}
+		switch {
+		case die.SuccessOn.Match(rolls[i].Value):
+			rolls[i].Success = true
+			result.Successes++
+		case die.FailOn.Match(rolls[i].Value):
+			rolls[i].Failure = true
+			result.Failures++
+		}
+	}
+	result.Total = result.Successes
+	return result
+}
+
+// rollChain rolls a single die along with any explosions it triggers.
+// Standard and penetrating explosions add a die to the pool for every extra
+// roll; compounding explosions fold them into one die.
+func rollChain(die *Die, r RandIntn) ([]DieRoll, error) {
+	roll, err := rollFace(die, r)
+	if err != nil {
+		return nil, err
+	}
+	chain := []DieRoll{roll}
+	for n := 0; n < maxExplosions && die.explodes(roll.Natural); n++ {
+		chain[len(chain)-1].Exploded = true
+		if roll, err = rollFace(die, r); err != nil {
+			return nil, err
+		}
+		if die.Explode == ExplodePenetrate {
+			roll.Value--
+		}
+		chain = append(chain, roll)
+	}
+
+	if die.Explode != ExplodeCompound || len(chain) == 1 {
+		return chain, nil
+	}
+	compound := DieRoll{Natural: chain[0].Natural, Exploded: true}
+	for _, link := range chain {
+		compound.Value += link.Value
+		compound.Chain = append(compound.Chain, link.Value)
+		compound.Rerolls = append(compound.Rerolls, link.Rerolls...)
+	}
+	return []DieRoll{compound}, nil
+}
+
+// rollFace rolls a single face of die, applying its reroll and minimum
+// modifiers.
+func rollFace(die *Die, r RandIntn) (DieRoll, error) {
+	d, err := rollDraw(die, r)
+	if err != nil {
+		return DieRoll{}, err
+	}
+	roll := die.faceValue(d.n)
+	var rerolls []int
+	for i := 0; i < maxRerolls && die.rerolls(roll, i); i++ {
+		rerolls = append(rerolls, roll)
+		if d, err = rollDraw(die, r); err != nil {
+			return DieRoll{}, err
+		}
+		roll = die.faceValue(d.n)
+	}
+	if die.symbolic() {
+		return DieRoll{Face: die.Faces.Labels[d.n-1]}, nil
+	}
+	result := DieRoll{Value: roll, Natural: roll, Rerolls: rerolls, Digits: d.digits, ExtraTens: d.extraTens}
+	if die.Min != nil && roll < *die.Min {
+		result.Value = *die.Min
+		result.Floored = true
+	}
+	return result, nil
+}
+
+// draw is which face of a die came up, counting from 1, along with the
+// dice that were read positionally to pick it.
+type draw struct {
+	n         int
+	digits    []int
+	extraTens []int
+}
+
+// rollDraw picks a face of die, before any of its modifiers are applied.
+func rollDraw(die *Die, r RandIntn) (draw, error) {
+	switch {
+	case die.Faces != nil && die.Faces.DigitSides != 0:
+		return rollPositional(die.Faces, r)
+	case die.Bonus > 0 || die.Penalty > 0:
+		return rollPercentile(die, r)
+	}
+	n, err := rollOne(die.Sides, r)
+	return draw{n: n}, err
+}
+
+// rollPositional rolls one die per digit of a positional die such as a d66
+// and reads them as the digits of the face, e.g. 3 and 5 make 35.
+func rollPositional(faces *FaceSet, r RandIntn) (draw, error) {
+	var d draw
+	for size := 1; size < faces.size(); size *= faces.DigitSides {
+		digit, err := rollOne(faces.DigitSides, r)
+		if err != nil {
+			return draw{}, err
+		}
+		d.digits = append(d.digits, digit)
+		d.n = d.n*faces.DigitSides + digit - 1
+	}
+	d.n++
+	return d, nil
+}
+
+// rollPercentile rolls a d100 with bonus or penalty dice: one ones die and
+// several tens dice, keeping whichever tens die gives the lowest result for
+// bonus dice or the highest for penalty dice. 00 and 0 make 100.
+func rollPercentile(die *Die, r RandIntn) (draw, error) {
+	tens := make([]int, 1+die.Bonus+die.Penalty)
+	for i := range tens {
+		t, err := rollOne(10, r)
+		if err != nil {
+			return draw{}, err
+		}
+		tens[i] = t - 1
+	}
+	ones, err := rollOne(10, r)
+	if err != nil {
+		return draw{}, err
+	}
+	ones--
+
+	best := 0
+	for i, t := range tens {
+		v, bestV := percentileValue(t, ones), percentileValue(tens[best], ones)
+		if die.Bonus > 0 && v < bestV || die.Penalty > 0 && v > bestV {
+			best = i
+		}
+	}
+	d := draw{n: percentileValue(tens[best], ones), digits: []int{tens[best], ones}}
+	for i, t := range tens {
+		if i != best {
+			d.extraTens = append(d.extraTens, t*10)
+		}
+	}
+	return d, nil
+}
+
+// percentileValue reads a tens and a ones die, each showing 0 to 9, as a
+// roll from 1 to 100.
+func percentileValue(tens, ones int) int {
+	if tens == 0 && ones == 0 {
+		return 100
+	}
+	return tens*10 + ones
+}
+
+func rollOne(sides int, r RandIntn) (int, error) {
+	roll := r.Intn(sides) + 1
+	if roll < 1 || roll > sides {
+		return 0, fmt.Errorf("out of bounds somehow! %d > %d", roll, sides)
+	}
+	return roll, nil
+}
+
+// applyKeep marks the dice discarded by die's keep or drop modifier. Ties are
+// broken by roll order so the same rolls always drop the same dice.
+func applyKeep(die *Die, rolls []DieRoll) {
+	if die.Keep == KeepAll {
+		return
+	}
+	order := make([]int, len(rolls))
+	for i := range order {
+		order[i] = i
+	}
+	// Sort highest first; stable so equal dice keep their roll order.
+	sort.SliceStable(order, func(a, b int) bool {
+		return rolls[order[a]].Value > rolls[order[b]].Value
+	})
+
+	var drop []int
+	switch die.Keep {
+	case KeepHighest:
+		drop = order[die.KeepN:]
+	case KeepLowest:
+		drop = order[:len(order)-die.KeepN]
+	case DropHighest:
+		drop = order[:die.KeepN]
+	case DropLowest:
+		drop = order[len(order)-die.KeepN:]
+	}
+	for _, i := range drop {
+		rolls[i].Dropped = true
+	}
+}
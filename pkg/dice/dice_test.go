@@ -0,0 +1,562 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// mockRand is a mock random number generator that always returns a fixed value
+type mockRand struct {
+	value int
+}
+
+func (r *mockRand) Intn(n int) int {
+	return r.value
+}
+
+// seqRand is a mock random number generator that returns values from a
+// fixed sequence, starting over when it runs out
+type seqRand struct {
+	values []int
+	i      int
+}
+
+func (r *seqRand) Intn(n int) int {
+	v := r.values[r.i%len(r.values)]
+	r.i++
+	return v
+}
+
+func TestBasicSixSidedDie(t *testing.T) {
+	r := &mockRand{value: 3}
+	input := "1d6"
+	expected := 4
+	result, err := RollDice(r, input, nil)
+
+	if err != nil {
+		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
+	}
+
+	if result.Total != expected {
+		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
+	}
+}
+
+func Test_parse(t *testing.T) {
+	input := "1d6"
+	expected := Die{Sides: 6, Count: 1}
+	result, err := parse(input, Options{})
+
+	if err != nil {
+		t.Fatalf("parse(%s) returned error %v; want %v", input, err, expected)
+	}
+
+	dice, ok := result.(*diceNode)
+	if !ok || dice.die != expected {
+		t.Errorf("parse(%s) = %v; want %v", input, result, expected)
+	}
+}
+
+func Test_Complex_parse(t *testing.T) {
+	input := "1d6+2d4+2+1d8+4"
+	result, err := parse(input, Options{})
+
+	if err != nil {
+		t.Fatalf("parse(%s) returned error %v", input, err)
+	}
+
+	if result.String() != input {
+		t.Errorf("parse(%s) = %v; want %v", input, result, input)
+	}
+}
+
+func TestOutOfBoundsReturnsError(t *testing.T) {
+	r := &mockRand{value: 8}
+	input := "1d6"
+	expected := -1
+	result, err := RollDice(r, input, nil)
+
+	if result.Total != expected {
+		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
+		return
+	}
+
+	if err == nil {
+		t.Errorf("RollDice(%s) = %d; want error", input, result.Total)
+		return
+	}
+
+	if err.Error() != "out of bounds somehow! 9 > 6" {
+		t.Errorf("RollDice(%s) = %s; want error", input, err)
+		return
+	}
+}
+
+func TestAddingModifiers(t *testing.T) {
+	r := &mockRand{value: 3}
+	input := "1d6+2"
+	expected := 6
+	result, err := RollDice(r, input, nil)
+
+	if err != nil {
+		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
+	}
+
+	if result.Total != expected {
+		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
+	}
+}
+
+func TestNegativeModifier(t *testing.T) {
+	r := &mockRand{value: 3}
+	input := "1d6-2"
+	expected := 2
+	result, err := RollDice(r, input, nil)
+	if err != nil {
+		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
+	}
+	if result.Total != expected {
+		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
+	}
+}
+
+func TestMissingDieCount(t *testing.T) {
+	r := &mockRand{value: 3}
+	input := "d6"
+	expected := 4
+	result, err := RollDice(r, input, nil)
+	if err != nil {
+		t.Errorf("RollDice(%s) = %d; want %d; error %v", input, result.Total, expected, err)
+	}
+	if result.Total != expected {
+		t.Errorf("RollDice(%s) = %d; want %d", input, result.Total, expected)
+	}
+}
+
+func TestKeepAndDrop(t *testing.T) {
+	// Each die rolls one more than the value returned, so these roll 3, 1, 6, 4
+	values := []int{2, 0, 5, 3}
+	tests := []struct {
+		input    string
+		expected int
+		dropped  []bool
+	}{
+		{input: "4d6kh3", expected: 13, dropped: []bool{false, true, false, false}},
+		{input: "4d6kl1", expected: 1, dropped: []bool{true, false, true, true}},
+		{input: "4d6dh1", expected: 8, dropped: []bool{false, false, true, false}},
+		{input: "4d6dl2", expected: 10, dropped: []bool{true, true, false, false}},
+		{input: "4d6kh", expected: 6, dropped: []bool{true, true, false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := RollDice(&seqRand{values: values}, tt.input, nil)
+			if err != nil {
+				t.Fatalf("RollDice(%s) returned error %v", tt.input, err)
+			}
+			if result.Total != tt.expected {
+				t.Errorf("RollDice(%s) = %d; want %d", tt.input, result.Total, tt.expected)
+			}
+			for i, roll := range result.Results[0].Rolls {
+				if roll.Dropped != tt.dropped[i] {
+					t.Errorf("RollDice(%s) die %d dropped = %v; want %v", tt.input, i, roll.Dropped, tt.dropped[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKeepHighestTiesAreDeterministic(t *testing.T) {
+	result, err := RollDice(&mockRand{value: 3}, "2d20kh1", nil)
+	if err != nil {
+		t.Fatalf("RollDice returned error %v", err)
+	}
+	rolls := result.Results[0].Rolls
+	if rolls[0].Dropped || !rolls[1].Dropped {
+		t.Errorf("2d20kh1 with equal dice = %+v; want the first kept", rolls)
+	}
+}
+
+func TestExplodingDice(t *testing.T) {
+	// Each die rolls one more than the value returned, so 5 rolls a 6
+	tests := []struct {
+		input    string
+		values   []int
+		expected int
+		rolls    []int
+	}{
+		{input: "2d6!", values: []int{5, 5, 2, 3}, expected: 19, rolls: []int{6, 6, 3, 4}},
+		{input: "2d6!!", values: []int{5, 5, 2, 3}, expected: 19, rolls: []int{15, 4}},
+		{input: "1d6!p", values: []int{5, 5, 2}, expected: 13, rolls: []int{6, 5, 2}},
+		{input: "1d10!>=9", values: []int{8, 1}, expected: 11, rolls: []int{9, 2}},
+		{input: "1d6!", values: []int{2}, expected: 3, rolls: []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := RollDice(&seqRand{values: tt.values}, tt.input, nil)
+			if err != nil {
+				t.Fatalf("RollDice(%s) returned error %v", tt.input, err)
+			}
+			if result.Total != tt.expected {
+				t.Errorf("RollDice(%s) = %d; want %d", tt.input, result.Total, tt.expected)
+			}
+			rolls := result.Results[0].Rolls
+			if len(rolls) != len(tt.rolls) {
+				t.Fatalf("RollDice(%s) rolls = %+v; want %v", tt.input, rolls, tt.rolls)
+			}
+			for i, roll := range rolls {
+				if roll.Value != tt.rolls[i] {
+					t.Errorf("RollDice(%s) die %d = %d; want %d", tt.input, i, roll.Value, tt.rolls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCompoundingRecordsChain(t *testing.T) {
+	result, err := RollDice(&seqRand{values: []int{5, 5, 2}}, "1d6!!", nil)
+	if err != nil {
+		t.Fatalf("RollDice returned error %v", err)
+	}
+	roll := result.Results[0].Rolls[0]
+	if !roll.Exploded || len(roll.Chain) != 3 || roll.Chain[0] != 6 || roll.Chain[2] != 3 {
+		t.Errorf("1d6!! = %+v; want an exploded die with chain [6 6 3]", roll)
+	}
+}
+
+func TestExplosionsAreCapped(t *testing.T) {
+	result, err := RollDice(&mockRand{value: 5}, "1d6!", nil)
+	if err != nil {
+		t.Fatalf("RollDice returned error %v", err)
+	}
+	if len(result.Results[0].Rolls) != maxExplosions+1 {
+		t.Errorf("1d6! always rolling 6 produced %d dice; want %d", len(result.Results[0].Rolls), maxExplosions+1)
+	}
+}
+
+func TestRerollsAndMinimums(t *testing.T) {
+	// Each die rolls one more than the value returned
+	tests := []struct {
+		input    string
+		values   []int
+		expected int
+		rerolls  [][]int
+	}{
+		{input: "2d6r<3", values: []int{0, 1, 3, 4}, expected: 9, rerolls: [][]int{{1, 2}, nil}},
+		{input: "1d20ro1", values: []int{0, 0}, expected: 1, rerolls: [][]int{{1}}},
+		{input: "1d20ro1", values: []int{9}, expected: 10, rerolls: [][]int{nil}},
+		{input: "1d6r6", values: []int{5, 5, 5, 2}, expected: 3, rerolls: [][]int{{6, 6, 6}}},
+		{input: "3d6min3", values: []int{0, 1, 4}, expected: 11, rerolls: [][]int{nil, nil, nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := RollDice(&seqRand{values: tt.values}, tt.input, nil)
+			if err != nil {
+				t.Fatalf("RollDice(%s) returned error %v", tt.input, err)
+			}
+			if result.Total != tt.expected {
+				t.Errorf("RollDice(%s) = %d; want %d", tt.input, result.Total, tt.expected)
+			}
+			for i, roll := range result.Results[0].Rolls {
+				if !slices.Equal(roll.Rerolls, tt.rerolls[i]) {
+					t.Errorf("RollDice(%s) die %d rerolls = %v; want %v", tt.input, i, roll.Rerolls, tt.rerolls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMinimumKeepsNaturalRoll(t *testing.T) {
+	result, err := RollDice(&mockRand{value: 0}, "1d6min2", nil)
+	if err != nil {
+		t.Fatalf("RollDice returned error %v", err)
+	}
+	roll := result.Results[0].Rolls[0]
+	if roll.Value != 2 || roll.Natural != 1 || !roll.Floored {
+		t.Errorf("1d6min2 rolling a 1 = %+v; want value 2 from a natural 1", roll)
+	}
+}
+
+func TestMinimumOfZeroOnFateDice(t *testing.T) {
+	for _, input := range []string{"4dFmin0", "4d{-1,0,1}min0"} {
+		e, err := Parse(input, Options{})
+		if err != nil {
+			t.Fatalf("Parse(%s) returned error %v", input, err)
+		}
+		if got := e.String(); !strings.HasSuffix(got, "min0") {
+			t.Errorf("Parse(%s).String() = %q; want it to keep min0", input, got)
+		}
+
+		// Every die lands on -1 and is raised to 0.
+		result, err := e.Roll(&mockRand{value: 0})
+		if err != nil {
+			t.Fatalf("Roll(%s) returned error %v", input, err)
+		}
+		if result.Total != 0 {
+			t.Errorf("Roll(%s) = %d; want 0", input, result.Total)
+		}
+		for _, roll := range result.Results[0].Rolls {
+			if roll.Value != 0 || roll.Natural != -1 || !roll.Floored {
+				t.Errorf("Roll(%s) die = %+v; want a natural -1 raised to 0", input, roll)
+			}
+		}
+
+		dist, err := e.Distribution()
+		if err != nil {
+			t.Fatalf("Distribution(%s) returned error %v", input, err)
+		}
+		if values := dist.Values(); values[0] != 0 || values[len(values)-1] != 4 {
+			t.Errorf("Distribution(%s) runs from %d to %d; want 0 to 4", input, values[0], values[len(values)-1])
+		}
+
+		data, err := json.Marshal(result.Results[0].Die)
+		if err != nil {
+			t.Fatalf("json.Marshal returned error %v", err)
+		}
+		if !strings.Contains(string(data), `"min":0`) {
+			t.Errorf("JSON for %s = %s; want it to include min 0", input, data)
+		}
+	}
+}
+
+// TestHugeDiceParseAndRoll checks that modifiers on dice with a huge number
+// of sides don't walk every face, which would run out of memory.
+func TestHugeDiceParseAndRoll(t *testing.T) {
+	tests := []struct {
+		input    string
+		values   []int
+		expected int
+	}{
+		{input: "d1000000000!", values: []int{999999999, 4}, expected: 1000000005},
+		{input: "d1000000000r<3", values: []int{0, 1, 6}, expected: 7},
+		{input: "d1000000000min5", values: []int{0}, expected: 5},
+	}
+	for _, tt := range tests {
+		result, err := RollDice(&seqRand{values: tt.values}, tt.input, nil)
+		if err != nil {
+			t.Fatalf("RollDice(%s) returned error %v", tt.input, err)
+		}
+		if result.Total != tt.expected {
+			t.Errorf("RollDice(%s) = %d; want %d", tt.input, result.Total, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"d1000000000!>=1", "d1000000000r<=1000000000", "d1000000000min1000000001"} {
+		if _, err := Parse(input, Options{}); err == nil {
+			t.Errorf("Parse(%s) succeeded; want an error", input)
+		}
+	}
+}
+
+func TestSuccessPools(t *testing.T) {
+	// Each die rolls one more than the value returned, so these roll 8, 1, 10, 5
+	values := []int{7, 0, 9, 4}
+	tests := []struct {
+		input     string
+		successes int
+		failures  int
+		botch     bool
+	}{
+		{input: "4d10>=8", successes: 2},
+		{input: "4d10>=8f1", successes: 2, failures: 1},
+		{input: "4d10=10f<=5", successes: 1, failures: 2},
+		{input: "4d10>10f1", successes: 0, failures: 1, botch: true},
+		{input: "4d10>=8+1", successes: 3},
+		{input: "4d10kh2>=9", successes: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := RollDice(&seqRand{values: values}, tt.input, nil)
+			if err != nil {
+				t.Fatalf("RollDice(%s) returned error %v", tt.input, err)
+			}
+			if result.Mode != ModeSuccesses {
+				t.Errorf("RollDice(%s) mode = %v; want ModeSuccesses", tt.input, result.Mode)
+			}
+			if result.Successes != tt.successes || result.Total != tt.successes {
+				t.Errorf("RollDice(%s) successes = %d (total %d); want %d", tt.input, result.Successes, result.Total, tt.successes)
+			}
+			if result.Failures != tt.failures {
+				t.Errorf("RollDice(%s) failures = %d; want %d", tt.input, result.Failures, tt.failures)
+			}
+			if result.Botch != tt.botch {
+				t.Errorf("RollDice(%s) botch = %v; want %v", tt.input, result.Botch, tt.botch)
+			}
+		})
+	}
+}
+
+func TestSuccessPoolsCannotMixWithSums(t *testing.T) {
+	_, err := RollDice(&mockRand{value: 3}, "5d10>=8+1d6", nil)
+	if err == nil {
+		t.Error("RollDice(5d10>=8+1d6) succeeded; want error")
+	}
+}
+
+func TestCustomFaces(t *testing.T) {
+	// The value returned picks the face one after it, so 0 picks the first
+	// face
+	tests := []struct {
+		input    string
+		values   []int
+		expected int
+	}{
+		{input: "3dF", values: []int{2, 2, 0}, expected: 1},
+		{input: "3d{-1,0,0,1,2,3}", values: []int{0, 4, 5}, expected: 4},
+		{input: "3d{-1,0,0,1,2,3}kh1", values: []int{0, 4, 5}, expected: 3},
+		{input: "3d{1,3,5,7}>=5", values: []int{3, 1, 2}, expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := RollDice(&seqRand{values: tt.values}, tt.input, nil)
+			if err != nil {
+				t.Fatalf("RollDice(%s) returned error %v", tt.input, err)
+			}
+			if result.Total != tt.expected {
+				t.Errorf("RollDice(%s) = %d; want %d", tt.input, result.Total, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSymbolicFacesAreTallied(t *testing.T) {
+	result, err := RollDice(&seqRand{values: []int{1, 2, 1, 1}}, "3d{hit,miss,crit,hit}+d{hit,blank}", nil)
+	if err != nil {
+		t.Fatalf("RollDice returned error %v", err)
+	}
+	if result.Mode != ModeSymbols {
+		t.Errorf("mode = %v; want ModeSymbols", result.Mode)
+	}
+	expected := []FaceCount{{Face: "miss", Count: 2}, {Face: "crit", Count: 1}, {Face: "blank", Count: 1}}
+	if !slices.Equal(result.Tally, expected) {
+		t.Errorf("tally = %v; want %v", result.Tally, expected)
+	}
+}
+
+func TestSymbolicDiceCannotMixWithSums(t *testing.T) {
+	for _, input := range []string{"d{a,b}+1d6", "d{a,b}*2", "-d{a,b}", "d{a,b}-d{a,b}"} {
+		if _, err := RollDice(&mockRand{value: 0}, input, nil); err == nil {
+			t.Errorf("RollDice(%s) succeeded; want error", input)
+		}
+	}
+}
+
+func TestCriticalRolls(t *testing.T) {
+	// Each die rolls one more than the value returned, so these roll 20, 1, 19, 2
+	values := []int{19, 0, 18, 1}
+	tests := []struct {
+		input         string
+		critSuccesses int
+		critFailures  int
+		crits         []bool
+		fumbles       []bool
+	}{
+		{input: "1d20cs20", critSuccesses: 1, crits: []bool{true}, fumbles: []bool{false}},
+		{input: "4d20cs>=19cf1", critSuccesses: 2, critFailures: 1, crits: []bool{true, false, true, false}, fumbles: []bool{false, true, false, false}},
+		{input: "2d20kl1cs20cf1", critFailures: 1, crits: []bool{false, false}, fumbles: []bool{false, true}},
+		{input: "4d20min5cf1", critFailures: 1, crits: []bool{false, false, false, false}, fumbles: []bool{false, true, false, false}},
+		{input: "4d20>=15cs20", critSuccesses: 1, crits: []bool{true, false, false, false}, fumbles: []bool{false, false, false, false}},
+		{input: "1d20+5", crits: []bool{false}, fumbles: []bool{false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := RollDice(&seqRand{values: values}, tt.input, nil)
+			if err != nil {
+				t.Fatalf("RollDice(%s) returned error %v", tt.input, err)
+			}
+			if result.CritSuccesses != tt.critSuccesses || result.CritFailures != tt.critFailures {
+				t.Errorf("RollDice(%s) crits = %d successes, %d failures; want %d, %d", tt.input, result.CritSuccesses, result.CritFailures, tt.critSuccesses, tt.critFailures)
+			}
+			rolls := result.Results[0].Rolls
+			for i, roll := range rolls {
+				if roll.CritSuccess != tt.crits[i] || roll.CritFailure != tt.fumbles[i] {
+					t.Errorf("RollDice(%s) die %d = %+v; want crit %v, fumble %v", tt.input, i, roll, tt.crits[i], tt.fumbles[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPercentileDice(t *testing.T) {
+	tests := []struct {
+		input     string
+		values    []int
+		total     int
+		digits    []int
+		extraTens []int
+	}{
+		{input: "d100b1", values: []int{6, 2, 3}, total: 23, digits: []int{2, 3}, extraTens: []int{60}},
+		{input: "d100p1", values: []int{6, 2, 3}, total: 63, digits: []int{6, 3}, extraTens: []int{20}},
+		// 00 and 0 make 100, the worst result rather than the best
+		{input: "d100b1", values: []int{0, 4, 0}, total: 40, digits: []int{4, 0}, extraTens: []int{0}},
+		{input: "d100p2", values: []int{0, 4, 9, 0}, total: 100, digits: []int{0, 0}, extraTens: []int{40, 90}},
+	}
+
+	for _, tt := range tests {
+		result, err := RollDice(&seqRand{values: tt.values}, tt.input, nil)
+		if err != nil {
+			t.Fatalf("RollDice(%s) returned error %v", tt.input, err)
+		}
+		roll := result.Results[0].Rolls[0]
+		if result.Total != tt.total || !slices.Equal(roll.Digits, tt.digits) || !slices.Equal(roll.ExtraTens, tt.extraTens) {
+			t.Errorf("RollDice(%s) with %v = %d %+v; want %d with digits %v and extra tens %v", tt.input, tt.values, result.Total, roll, tt.total, tt.digits, tt.extraTens)
+		}
+	}
+}
+
+func TestPositionalDice(t *testing.T) {
+	tests := []struct {
+		input  string
+		values []int
+		total  int
+		digits []int
+	}{
+		{input: "d66", values: []int{2, 4}, total: 35, digits: []int{3, 5}},
+		{input: "d666", values: []int{0, 5, 2}, total: 163, digits: []int{1, 6, 3}},
+		{input: "d66!", values: []int{5, 5, 0, 0}, total: 77, digits: []int{6, 6}},
+	}
+
+	for _, tt := range tests {
+		result, err := RollDice(&seqRand{values: tt.values}, tt.input, nil)
+		if err != nil {
+			t.Fatalf("RollDice(%s) returned error %v", tt.input, err)
+		}
+		roll := result.Results[0].Rolls[0]
+		if result.Total != tt.total || !slices.Equal(roll.Digits, tt.digits) {
+			t.Errorf("RollDice(%s) with %v = %d %+v; want %d with digits %v", tt.input, tt.values, result.Total, roll, tt.total, tt.digits)
+		}
+	}
+}
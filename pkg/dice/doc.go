@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package dice parses and rolls tabletop dice expressions such as
+// "4d6kh3", "(1d8+3)*2" or "10d10>=8f1", and works out their odds.
+//
+// The simplest way to roll is RollDice. Expressions that are rolled
+// repeatedly, or that refer to @macros, are parsed once with Parse and then
+// rolled with Expression.Roll. Every roll takes a RandIntn to draw from:
+// SeededRand gives results that can be replayed from a seed, and CryptoRand
+// draws from crypto/rand.
+//
+// The notation, from lowest to highest precedence:
+//
+//	expr     = term { ("+" | "-") term }
+//	term     = unary { ("*" | "/") unary }
+//	unary    = "-" unary | primary
+//	primary  = number | dice | macro | variable | "(" expr ")"
+//	macro    = "@" name
+//	variable = "$" name
+//	dice     = [number] ("d" number | "dF" | "d{" face { "," face } "}") { modifier }
+//	modifier = ("kh" | "kl" | "dh" | "dl") [number]
+//	         | "!" ["!" | "p"] [compare]
+//	         | ("r" | "ro") compare
+//	         | "min" number
+//	         | compare              (count successes)
+//	         | "f" compare          (count failures)
+//	         | "cs" compare         (critical success)
+//	         | "cf" compare         (critical failure)
+//	         | ("b" | "p") [number] (bonus or penalty dice, d100 only)
+//	compare  = (">=" | "<=" | ">" | "<" | "=") number | number
+//
+// Division rounds down. A d66 or d666 rolls a d6 for each digit and reads
+// them positionally, so d66 gives 11 to 66. Dice whose faces aren't all
+// numbers, such as "3d{hit,miss,crit}", are tallied instead of summed and
+// can only be added to other such dice.
+//
+// Narrative dice for Genesys and the Star Wars roleplaying games are rolled
+// separately with RollNarrative, which cancels their symbols against each
+// other.
+package dice
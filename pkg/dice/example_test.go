@@ -0,0 +1,112 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bdunn313/workbench/pkg/dice"
+)
+
+func ExampleRollDice() {
+	result, err := dice.RollDice(dice.NewSeededRand(42), "4d6kh3+2", nil)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println(result.Total, "=", result.Breakdown)
+	for _, roll := range result.Results[0].Rolls {
+		fmt.Print(roll.Value, " dropped=", roll.Dropped, "\n")
+	}
+	// Output:
+	// 7 = 5 + 2
+	// 2 dropped=false
+	// 2 dropped=false
+	// 1 dropped=false
+	// 1 dropped=true
+}
+
+func ExampleParse() {
+	e, err := dice.Parse("@attack", dice.Options{
+		Macros: map[string]string{"attack": "1d20+$str+$prof"},
+		Vars:   dice.Vars{"str": 3, "prof": 2},
+	})
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	r := dice.NewSeededRand(7)
+	for i := 0; i < 3; i++ {
+		result, err := e.Roll(r)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println(result.Total, "=", result.Breakdown)
+	}
+	// Output:
+	// 13 = (8 + $str(3) + $prof(2))
+	// 10 = (5 + $str(3) + $prof(2))
+	// 12 = (7 + $str(3) + $prof(2))
+}
+
+func ExampleParse_error() {
+	_, err := dice.Parse("2d6+", dice.Options{})
+	var parseErr *dice.ParseError
+	if errors.As(err, &parseErr) {
+		fmt.Println(parseErr.Pointer())
+		fmt.Println(err)
+	}
+	// Output:
+	// 2d6+
+	//     ^
+	// expected a number, dice or "(" but found end of expression at position 5
+}
+
+func ExampleExpression_Distribution() {
+	e, err := dice.Parse("2d20kh1", dice.Options{})
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	dist, err := e.Distribution()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("mean %.3f, %.0f%% chance of 15 or more\n", dist.Mean(), dist.AtLeast(15)*100)
+	// Output:
+	// mean 13.825, 51% chance of 15 or more
+}
+
+func ExampleRollDice_successPool() {
+	result, err := dice.RollDice(dice.NewSeededRand(3), "8d10>=8f1", nil)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println(result.Successes, "successes,", result.Failures, "failure, botch:", result.Botch)
+	// Output:
+	// 2 successes, 1 failure, botch: false
+}
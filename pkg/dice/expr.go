@@ -0,0 +1,883 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxDiceCount caps how many dice a single term may roll so a typo like
+// 1000000000d6 can't hang the program.
+const maxDiceCount = 10000
+
+// maxTensDice caps how many bonus or penalty dice a d100 can have.
+const maxTensDice = 10
+
+// ParseError describes a malformed dice expression. Pos is the zero-based
+// byte offset into the expression where the problem was found.
+type ParseError struct {
+	Expression string
+	Pos        int
+	Msg        string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos+1)
+}
+
+// Pointer renders the expression with a caret under the offending position.
+func (e *ParseError) Pointer() string {
+	return e.Expression + "\n" + strings.Repeat(" ", e.Pos) + "^"
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokPlus
+	tokMinus
+	tokStar
+	tokSlash
+	tokLParen
+	tokRParen
+	tokBang
+	tokCompare
+	tokFaces
+	tokMacro
+	tokVar
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) describe() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var symbolTokens = map[rune]tokenKind{
+	'+': tokPlus,
+	'-': tokMinus,
+	'*': tokStar,
+	'/': tokSlash,
+	'(': tokLParen,
+	')': tokRParen,
+	'!': tokBang,
+	'>': tokCompare,
+	'<': tokCompare,
+	'=': tokCompare,
+}
+
+// tokenize splits a dice expression into tokens. Identifiers are runs of
+// letters only, so "2d20" lexes as 2, d, 20. The faces of a custom die are
+// kept together as a single token.
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(expression) {
+		c := rune(expression[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(expression) && unicode.IsDigit(rune(expression[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: expression[start:i], pos: start})
+		case unicode.IsLetter(c):
+			start := i
+			for i < len(expression) && unicode.IsLetter(rune(expression[i])) {
+				i++
+			}
+			// Fate dice are written "dF" and may be followed directly by a
+			// modifier, as in "4dFkh2".
+			if strings.HasPrefix(expression[start:i], "dF") {
+				i = start + 2
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: expression[start:i], pos: start})
+		case c == '@':
+			start := i
+			i++
+			for i < len(expression) && isMacroNameChar(rune(expression[i])) {
+				i++
+			}
+			if i == start+1 {
+				return nil, &ParseError{Expression: expression, Pos: start, Msg: "expected a macro name after \"@\""}
+			}
+			tokens = append(tokens, token{kind: tokMacro, text: expression[start+1 : i], pos: start})
+		case c == '$':
+			start := i
+			i++
+			for i < len(expression) && isVarNameChar(rune(expression[i])) {
+				i++
+			}
+			if i == start+1 {
+				return nil, &ParseError{Expression: expression, Pos: start, Msg: "expected a variable name after \"$\""}
+			}
+			tokens = append(tokens, token{kind: tokVar, text: expression[start+1 : i], pos: start})
+		case c == '{':
+			end := strings.IndexByte(expression[i:], '}')
+			if end < 0 {
+				return nil, &ParseError{Expression: expression, Pos: i, Msg: "missing closing \"}\""}
+			}
+			tokens = append(tokens, token{kind: tokFaces, text: expression[i+1 : i+end], pos: i})
+			i += end + 1
+		default:
+			kind, ok := symbolTokens[c]
+			if !ok {
+				return nil, &ParseError{Expression: expression, Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			text := string(c)
+			if (c == '>' || c == '<') && i+1 < len(expression) && expression[i+1] == '=' {
+				text += "="
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: i})
+			i += len(text)
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(expression)})
+	return tokens, nil
+}
+
+// CheckMacroName returns an error if name can't be used as a macro. Names
+// are made up of letters, digits and underscores, and don't start with a
+// digit.
+func CheckMacroName(name string) error {
+	if name == "" {
+		return errors.New("macro name can't be empty")
+	}
+	for _, c := range name {
+		if !isMacroNameChar(c) {
+			return fmt.Errorf("macro name %q can only contain letters, digits and _", name)
+		}
+	}
+	if unicode.IsDigit(rune(name[0])) {
+		return fmt.Errorf("macro name %q can't start with a digit", name)
+	}
+	return nil
+}
+
+func isMacroNameChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_'
+}
+
+// isVarNameChar also allows dots, which name values nested in a character
+// sheet, e.g. $skills.stealth.
+func isVarNameChar(c rune) bool {
+	return isMacroNameChar(c) || c == '.'
+}
+
+// exprNode is a node in a parsed dice expression. eval returns the value of
+// the node along with a breakdown showing what each die term rolled.
+type exprNode interface {
+	eval(e *evaluator) (int, string, error)
+	String() string
+}
+
+// evaluator carries the random source and collects one RollResult per dice
+// term while a tree is evaluated.
+type evaluator struct {
+	r       RandIntn
+	results []RollResult
+}
+
+type numberNode struct {
+	value int
+}
+
+func (n *numberNode) eval(e *evaluator) (int, string, error) {
+	return n.value, strconv.Itoa(n.value), nil
+}
+
+func (n *numberNode) String() string {
+	return strconv.Itoa(n.value)
+}
+
+type diceNode struct {
+	die Die
+}
+
+func (n *diceNode) eval(e *evaluator) (int, string, error) {
+	result, err := generateResult(&n.die, e.r)
+	if err != nil {
+		return 0, "", err
+	}
+	e.results = append(e.results, result)
+	return result.Total, strconv.Itoa(result.Total), nil
+}
+
+func (n *diceNode) String() string {
+	return n.die.String()
+}
+
+type negateNode struct {
+	operand exprNode
+}
+
+func (n *negateNode) eval(e *evaluator) (int, string, error) {
+	value, text, err := n.operand.eval(e)
+	if err != nil {
+		return 0, "", err
+	}
+	return -value, "-" + text, nil
+}
+
+func (n *negateNode) String() string {
+	return "-" + n.operand.String()
+}
+
+type groupNode struct {
+	inner exprNode
+}
+
+func (n *groupNode) eval(e *evaluator) (int, string, error) {
+	value, text, err := n.inner.eval(e)
+	if err != nil {
+		return 0, "", err
+	}
+	return value, "(" + text + ")", nil
+}
+
+func (n *groupNode) String() string {
+	return "(" + n.inner.String() + ")"
+}
+
+type binaryNode struct {
+	op          byte
+	left, right exprNode
+}
+
+// macroNode is a saved expression referred to by name, such as @longsword.
+type macroNode struct {
+	name  string
+	inner exprNode
+}
+
+func (n *macroNode) eval(e *evaluator) (int, string, error) {
+	value, text, err := n.inner.eval(e)
+	if err != nil {
+		return 0, "", err
+	}
+	if _, ok := n.inner.(*binaryNode); ok {
+		text = "(" + text + ")"
+	}
+	return value, text, nil
+}
+
+func (n *macroNode) String() string {
+	return "@" + n.name
+}
+
+// varNode is a variable such as $str, whose value is looked up when the
+// expression is parsed.
+type varNode struct {
+	name  string
+	value int
+}
+
+func (n *varNode) eval(e *evaluator) (int, string, error) {
+	return n.value, fmt.Sprintf("$%s(%d)", n.name, n.value), nil
+}
+
+func (n *varNode) String() string {
+	return "$" + n.name
+}
+
+func (n *binaryNode) eval(e *evaluator) (int, string, error) {
+	left, leftText, err := n.left.eval(e)
+	if err != nil {
+		return 0, "", err
+	}
+	right, rightText, err := n.right.eval(e)
+	if err != nil {
+		return 0, "", err
+	}
+	value, err := applyOperator(n.op, left, right)
+	if err != nil {
+		return 0, "", err
+	}
+	return value, leftText + " " + string(n.op) + " " + rightText, nil
+}
+
+func (n *binaryNode) String() string {
+	return n.left.String() + string(n.op) + n.right.String()
+}
+
+// applyOperator combines two values. Division rounds down, as most tabletop
+// games expect.
+func applyOperator(op byte, left, right int) (int, error) {
+	switch op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		q := left / right
+		if (left%right != 0) && ((left < 0) != (right < 0)) {
+			q--
+		}
+		return q, nil
+	}
+	return 0, fmt.Errorf("unknown operator %q", op)
+}
+
+type parser struct {
+	expression string
+	tokens     []token
+	pos        int
+	opts       Options
+	// expanding lists the macros currently being parsed so cycles can be
+	// caught.
+	expanding []string
+}
+
+// Vars are the values $variables in a dice expression refer to, keyed by
+// their lowercase names.
+type Vars map[string]int
+
+// Options is what the names in an expression refer to: saved macros for
+// @name and variables for $name.
+type Options struct {
+	// Macros maps lowercase macro names to the expressions they stand for.
+	Macros map[string]string
+	Vars   Vars
+	// AnyVar accepts variables that aren't in Vars, treating them as 0, so
+	// an expression can be checked before the sheet it'll be rolled with is
+	// known.
+	AnyVar bool
+}
+
+// Expression is a parsed dice expression. It can be rolled any number of
+// times without being parsed again.
+type Expression struct {
+	text string
+	root exprNode
+}
+
+// Parse parses a dice expression such as "(1d8+3)*2", looking up any
+// macros and variables it uses in opts. Malformed expressions return a
+// *ParseError.
+func Parse(expression string, opts Options) (*Expression, error) {
+	root, err := parse(expression, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{text: expression, root: root}, nil
+}
+
+// String returns the expression as it was written.
+func (e *Expression) String() string {
+	return e.text
+}
+
+// Roll rolls the expression once using r.
+func (e *Expression) Roll(r RandIntn) (TotalRollResult, error) {
+	result, err := rollTree(r, e.root)
+	result.Expression = e.text
+	return result, err
+}
+
+// Symbolic reports whether the expression rolls symbolic dice, whose
+// results are tallied rather than totalled.
+func (e *Expression) Symbolic() bool {
+	symbolic, _ := checkSymbols(e.root)
+	return symbolic
+}
+
+// parse turns an expression into a tree that rollTree can evaluate.
+func parse(expression string, opts Options) (exprNode, error) {
+	node, err := parseTerms(expression, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := checkSymbols(node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// parseTerms parses a whole expression, or the body of a macro when
+// expanding is set.
+func parseTerms(expression string, opts Options, expanding []string) (exprNode, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{expression: expression, tokens: tokens, opts: opts, expanding: expanding}
+	if p.peek().kind == tokEOF {
+		return nil, p.errorf(p.peek(), "empty expression")
+	}
+	node, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok.describe())
+	}
+	return node, nil
+}
+
+// checkSymbols makes sure symbolic dice are only ever added to other
+// symbolic dice, since their faces have no numeric value. It reports whether
+// node rolls symbolic dice.
+func checkSymbols(node exprNode) (bool, error) {
+	errArithmetic := fmt.Errorf("symbolic dice can only be added to other symbolic dice")
+	switch n := node.(type) {
+	case *diceNode:
+		return n.die.symbolic(), nil
+	case *groupNode:
+		return checkSymbols(n.inner)
+	case *macroNode:
+		return checkSymbols(n.inner)
+	case *negateNode:
+		symbolic, err := checkSymbols(n.operand)
+		if symbolic {
+			return true, errArithmetic
+		}
+		return false, err
+	case *binaryNode:
+		left, err := checkSymbols(n.left)
+		if err != nil {
+			return false, err
+		}
+		right, err := checkSymbols(n.right)
+		if err != nil {
+			return false, err
+		}
+		if (left || right) && (n.op != '+' || left != right) {
+			return true, errArithmetic
+		}
+		return left, nil
+	}
+	return false, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	return &ParseError{Expression: p.expression, Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokPlus || p.peek().kind == tokMinus {
+		op := p.next().text[0]
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseProduct() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokStar || p.peek().kind == tokSlash {
+		op := p.next().text[0]
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (exprNode, error) {
+	if p.peek().kind == tokMinus {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negateNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (exprNode, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokNumber:
+		p.next()
+		value, err := p.parseInt(tok)
+		if err != nil {
+			return nil, err
+		}
+		if next := p.peek(); next.kind == tokIdent && (next.text == "d" || next.text == "dF") {
+			return p.parseDice(tok, value)
+		}
+		return &numberNode{value: value}, nil
+	case tokIdent:
+		if tok.text == "d" || tok.text == "dF" {
+			return p.parseDice(tok, 1)
+		}
+		return nil, p.errorf(tok, "unknown term %q", tok.text)
+	case tokMacro:
+		p.next()
+		return p.parseMacro(tok)
+	case tokVar:
+		p.next()
+		name := strings.ToLower(tok.text)
+		value, ok := p.opts.Vars[name]
+		if !ok && !p.opts.AnyVar {
+			return nil, p.errorf(tok, "unknown variable $%s", tok.text)
+		}
+		return &varNode{name: name, value: value}, nil
+	case tokLParen:
+		p.next()
+		inner, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			return nil, p.errorf(p.peek(), "expected \")\" but found %s", p.peek().describe())
+		}
+		p.next()
+		return &groupNode{inner: inner}, nil
+	}
+	return nil, p.errorf(tok, "expected a number, dice or \"(\" but found %s", tok.describe())
+}
+
+// parseMacro expands a reference to a saved expression. Errors inside the
+// macro point at the reference, since the macro's own text isn't shown.
+func (p *parser) parseMacro(tok token) (exprNode, error) {
+	name := strings.ToLower(tok.text)
+	expression, ok := p.opts.Macros[name]
+	if !ok {
+		return nil, p.errorf(tok, "unknown macro @%s", tok.text)
+	}
+	if slices.Contains(p.expanding, name) {
+		return nil, p.errorf(tok, "macro @%s refers to itself", tok.text)
+	}
+	inner, err := parseTerms(expression, p.opts, append(slices.Clone(p.expanding), name))
+	if err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			return nil, p.errorf(tok, "in macro @%s (%s): %s", tok.text, expression, parseErr.Msg)
+		}
+		return nil, err
+	}
+	return &macroNode{name: name, inner: inner}, nil
+}
+
+// parseDice parses the "d" and faces of a dice term. start is the token the
+// term began with, used to point errors at the whole term.
+func (p *parser) parseDice(start token, count int) (exprNode, error) {
+	d := p.next()
+	if count < 1 {
+		return nil, p.errorf(start, "dice count must be at least 1")
+	}
+	if count > maxDiceCount {
+		return nil, p.errorf(start, "dice count must be at most %d", maxDiceCount)
+	}
+	die, err := p.parseFaces(d)
+	if err != nil {
+		return nil, err
+	}
+	die.Count = count
+	for p.peek().kind == tokIdent || p.peek().kind == tokBang || p.peek().kind == tokCompare {
+		if die.symbolic() {
+			return nil, p.errorf(p.peek(), "symbolic dice can't have modifiers")
+		}
+		if err := p.parseModifier(&die); err != nil {
+			return nil, err
+		}
+	}
+	return &diceNode{die: die}, nil
+}
+
+// parseFaces parses what follows the "d" of a dice term: a number of sides
+// or a custom set of faces.
+func (p *parser) parseFaces(d token) (Die, error) {
+	if d.text == "dF" {
+		return Die{Sides: fateFaces.size(), Faces: fateFaces}, nil
+	}
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		if faces := positionalFaces(tok.text); faces != nil {
+			return Die{Sides: faces.size(), Faces: faces}, nil
+		}
+		sides, err := p.parseInt(tok)
+		if err != nil {
+			return Die{}, err
+		}
+		if sides < 2 {
+			return Die{}, p.errorf(tok, "dice must have at least 2 sides")
+		}
+		return Die{Sides: sides}, nil
+	case tokFaces:
+		faces, err := parseFaceSet(tok.text)
+		if err != nil {
+			return Die{}, p.errorf(tok, "%v", err)
+		}
+		return Die{Sides: faces.size(), Faces: faces}, nil
+	}
+	return Die{}, p.errorf(tok, "expected number of sides but found %s", tok.describe())
+}
+
+var keepModes = map[string]KeepMode{
+	"kh": KeepHighest,
+	"kl": KeepLowest,
+	"dh": DropHighest,
+	"dl": DropLowest,
+}
+
+// parseModifier parses one modifier following a dice term and applies it to
+// die.
+func (p *parser) parseModifier(die *Die) error {
+	if tok := p.peek(); tok.kind == tokCompare {
+		if die.countsSuccesses() {
+			return p.errorf(tok, "only one success condition is allowed")
+		}
+		cmp, err := p.parseCompare()
+		if err != nil {
+			return err
+		}
+		die.SuccessOn = cmp
+		return nil
+	}
+	tok := p.next()
+	if tok.kind == tokBang {
+		return p.parseExplode(tok, die)
+	}
+	switch tok.text {
+	case "f":
+		return p.parseFailure(tok, die)
+	case "r", "ro":
+		return p.parseReroll(tok, die)
+	case "min":
+		return p.parseMin(tok, die)
+	case "cs", "cf":
+		return p.parseCrit(tok, die)
+	case "b", "p":
+		return p.parseTensDice(tok, die)
+	}
+	mode, ok := keepModes[tok.text]
+	if !ok {
+		return p.errorf(tok, "unknown dice modifier %q", tok.text)
+	}
+	if die.Keep != KeepAll {
+		return p.errorf(tok, "only one keep or drop modifier is allowed")
+	}
+	n := 1
+	if p.peek().kind == tokNumber {
+		var err error
+		if n, err = p.parseInt(p.next()); err != nil {
+			return err
+		}
+	}
+	if n < 1 || n > die.Count {
+		return p.errorf(tok, "%s must select between 1 and %d dice", tok.text, die.Count)
+	}
+	die.Keep = mode
+	die.KeepN = n
+	return nil
+}
+
+// parseExplode parses the rest of an explode modifier after its first "!".
+func (p *parser) parseExplode(start token, die *Die) error {
+	if die.Explode != ExplodeNone {
+		return p.errorf(start, "only one explode modifier is allowed")
+	}
+	die.Explode = ExplodeStandard
+	switch next := p.peek(); {
+	case next.kind == tokBang:
+		p.next()
+		die.Explode = ExplodeCompound
+	case next.kind == tokIdent && next.text == "p":
+		p.next()
+		die.Explode = ExplodePenetrate
+	}
+	if p.peek().kind == tokCompare || p.peek().kind == tokNumber {
+		cmp, err := p.parseCompare()
+		if err != nil {
+			return err
+		}
+		die.ExplodeOn = cmp
+	}
+	if die.explodesOnEveryFace() {
+		return p.errorf(start, "dice would explode on every roll")
+	}
+	return nil
+}
+
+// parseReroll parses the condition of an "r" or "ro" modifier.
+func (p *parser) parseReroll(start token, die *Die) error {
+	if die.Reroll != RerollNone {
+		return p.errorf(start, "only one reroll modifier is allowed")
+	}
+	die.Reroll = RerollUntil
+	if start.text == "ro" {
+		die.Reroll = RerollOnce
+	}
+	cmp, err := p.parseCompare()
+	if err != nil {
+		return err
+	}
+	die.RerollOn = cmp
+	if die.Reroll == RerollUntil && die.rerollsEveryFace() {
+		return p.errorf(start, "dice would be rerolled forever")
+	}
+	return nil
+}
+
+func (p *parser) parseFailure(start token, die *Die) error {
+	if !die.countsSuccesses() {
+		return p.errorf(start, "failures can only be counted after a success condition like >=8")
+	}
+	if die.FailOn.Op != "" {
+		return p.errorf(start, "only one failure condition is allowed")
+	}
+	cmp, err := p.parseCompare()
+	if err != nil {
+		return err
+	}
+	die.FailOn = cmp
+	return nil
+}
+
+// parseCrit parses a critical success (cs) or failure (cf) condition.
+func (p *parser) parseCrit(start token, die *Die) error {
+	target := &die.CritSuccessOn
+	if start.text == "cf" {
+		target = &die.CritFailOn
+	}
+	if target.Op != "" {
+		return p.errorf(start, "only one %s modifier is allowed", start.text)
+	}
+	cmp, err := p.parseCompare()
+	if err != nil {
+		return err
+	}
+	*target = cmp
+	return nil
+}
+
+// parseTensDice parses the number of bonus (b) or penalty (p) dice rolled
+// with a d100.
+func (p *parser) parseTensDice(start token, die *Die) error {
+	if die.Sides != 100 || die.Faces != nil {
+		return p.errorf(start, "bonus and penalty dice can only be rolled with a d100")
+	}
+	if die.Bonus > 0 || die.Penalty > 0 {
+		return p.errorf(start, "only one bonus or penalty modifier is allowed")
+	}
+	n := 1
+	if p.peek().kind == tokNumber {
+		var err error
+		if n, err = p.parseInt(p.next()); err != nil {
+			return err
+		}
+	}
+	if n < 1 || n > maxTensDice {
+		return p.errorf(start, "%s must add between 1 and %d dice", start.text, maxTensDice)
+	}
+	if start.text == "b" {
+		die.Bonus = n
+	} else {
+		die.Penalty = n
+	}
+	return nil
+}
+
+func (p *parser) parseMin(start token, die *Die) error {
+	if die.Min != nil {
+		return p.errorf(start, "only one min modifier is allowed")
+	}
+	tok := p.peek()
+	if tok.kind != tokNumber {
+		return p.errorf(tok, "expected a number after \"min\" but found %s", tok.describe())
+	}
+	value, err := p.parseInt(p.next())
+	if err != nil {
+		return err
+	}
+	if value < die.lowestFace() || value > die.highestFace() {
+		return p.errorf(tok, "min must be between %d and %d", die.lowestFace(), die.highestFace())
+	}
+	die.Min = &value
+	return nil
+}
+
+// parseCompare parses a comparison such as ">=9". A bare number means "=".
+func (p *parser) parseCompare() (Compare, error) {
+	cmp := Compare{Op: "="}
+	if p.peek().kind == tokCompare {
+		cmp.Op = p.next().text
+	}
+	tok := p.peek()
+	if tok.kind != tokNumber {
+		return cmp, p.errorf(tok, "expected a number after %q but found %s", cmp.Op, tok.describe())
+	}
+	value, err := p.parseInt(p.next())
+	if err != nil {
+		return cmp, err
+	}
+	cmp.Value = value
+	return cmp, nil
+}
+
+func (p *parser) parseInt(tok token) (int, error) {
+	value, err := strconv.Atoi(tok.text)
+	if err != nil {
+		return 0, p.errorf(tok, "number %s is too large", tok.text)
+	}
+	return value, nil
+}
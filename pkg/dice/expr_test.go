@@ -0,0 +1,352 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRollDiceExpressions(t *testing.T) {
+	// mockRand{value: 3} makes every die roll a 4
+	tests := []struct {
+		input     string
+		expected  int
+		breakdown string
+	}{
+		{input: "5+1d6", expected: 9, breakdown: "5 + 4"},
+		{input: "2d6-1d4", expected: 4, breakdown: "8 - 4"},
+		{input: "(1d8+3)*2", expected: 14, breakdown: "(4 + 3) * 2"},
+		{input: "1d20-1d4", expected: 0, breakdown: "4 - 4"},
+		{input: "-1d6+10", expected: 6, breakdown: "-4 + 10"},
+		{input: "2+3*4", expected: 14, breakdown: "2 + 3 * 4"},
+		{input: "1d6/3", expected: 1, breakdown: "4 / 3"},
+		{input: "-7/2", expected: -4, breakdown: "-7 / 2"},
+		{input: " 2d6 + 1 ", expected: 9, breakdown: "8 + 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := RollDice(&mockRand{value: 3}, tt.input, nil)
+			if err != nil {
+				t.Fatalf("RollDice(%s) returned error %v", tt.input, err)
+			}
+			if result.Total != tt.expected {
+				t.Errorf("RollDice(%s) = %d; want %d", tt.input, result.Total, tt.expected)
+			}
+			if result.Breakdown != tt.breakdown {
+				t.Errorf("RollDice(%s) breakdown = %q; want %q", tt.input, result.Breakdown, tt.breakdown)
+			}
+		})
+	}
+}
+
+func TestRollDiceRecordsEachDiceTerm(t *testing.T) {
+	result, err := RollDice(&mockRand{value: 2}, "2d6+1d8", nil)
+	if err != nil {
+		t.Fatalf("RollDice returned error %v", err)
+	}
+	if len(result.Results) != 2 {
+		t.Fatalf("got %d results; want 2", len(result.Results))
+	}
+	if result.Results[0].RolledDie != "2d6" || len(result.Results[0].Rolls) != 2 {
+		t.Errorf("first result = %+v; want 2d6 with two rolls", result.Results[0])
+	}
+	if result.Results[1].RolledDie != "1d8" || result.Results[1].Total != 3 {
+		t.Errorf("second result = %+v; want 1d8 totalling 3", result.Results[1])
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{input: "", pos: 0},
+		{input: "1d", pos: 2},
+		{input: "2d6+", pos: 4},
+		{input: "(1d6", pos: 4},
+		{input: "1d6)", pos: 3},
+		{input: "1d6 % 2", pos: 4},
+		{input: "1x6", pos: 1},
+		{input: "0d6", pos: 0},
+		{input: "1d1", pos: 2},
+		{input: "1d6 2", pos: 4},
+		{input: "4d6kx3", pos: 3},
+		{input: "4d6kh5", pos: 3},
+		{input: "4d6kh3dl1", pos: 6},
+		{input: "1d6!>=1", pos: 3},
+		{input: "1d6!>", pos: 5},
+		{input: "1d6!!!", pos: 5},
+		{input: "1d6r<=6", pos: 3},
+		{input: "1d6r", pos: 4},
+		{input: "1d6min7", pos: 6},
+		{input: "1d6ro1r2", pos: 6},
+		{input: "5d10f1", pos: 4},
+		{input: "5d10>=8<3", pos: 7},
+		{input: "5d10>=", pos: 6},
+		{input: "d{a", pos: 1},
+		{input: "d{a,,b}", pos: 1},
+		{input: "d{a}", pos: 1},
+		{input: "2d{hit,miss}kh1", pos: 12},
+		{input: "dFx", pos: 2},
+		{input: "d20cs20cs19", pos: 7},
+		{input: "d20cf", pos: 5},
+		{input: "d20b1", pos: 3},
+		{input: "d66p1", pos: 3},
+		{input: "d100b1p1", pos: 6},
+		{input: "d100p11", pos: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := parse(tt.input, Options{})
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("parse(%q) error = %v; want *ParseError", tt.input, err)
+			}
+			if parseErr.Pos != tt.pos {
+				t.Errorf("parse(%q) error at %d (%v); want %d", tt.input, parseErr.Pos, err, tt.pos)
+			}
+		})
+	}
+}
+
+func TestParseDiceModifiers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Die
+	}{
+		{input: "4d6kh3", expected: Die{Sides: 6, Count: 4, Keep: KeepHighest, KeepN: 3}},
+		{input: "2d20kl", expected: Die{Sides: 20, Count: 2, Keep: KeepLowest, KeepN: 1}},
+		{input: "3d8dh1", expected: Die{Sides: 8, Count: 3, Keep: DropHighest, KeepN: 1}},
+		{input: "d6dl1", expected: Die{Sides: 6, Count: 1, Keep: DropLowest, KeepN: 1}},
+		{input: "d6!", expected: Die{Sides: 6, Count: 1, Explode: ExplodeStandard}},
+		{input: "d6!!", expected: Die{Sides: 6, Count: 1, Explode: ExplodeCompound}},
+		{input: "d6!p", expected: Die{Sides: 6, Count: 1, Explode: ExplodePenetrate}},
+		{input: "d10!>=9", expected: Die{Sides: 10, Count: 1, Explode: ExplodeStandard, ExplodeOn: Compare{Op: ">=", Value: 9}}},
+		{input: "d10!!10", expected: Die{Sides: 10, Count: 1, Explode: ExplodeCompound, ExplodeOn: Compare{Op: "=", Value: 10}}},
+		{input: "2d6r<3", expected: Die{Sides: 6, Count: 2, Reroll: RerollUntil, RerollOn: Compare{Op: "<", Value: 3}}},
+		{input: "d20ro1", expected: Die{Sides: 20, Count: 1, Reroll: RerollOnce, RerollOn: Compare{Op: "=", Value: 1}}},
+		{input: "4d6min2", expected: Die{Sides: 6, Count: 4, Min: intPtr(2)}},
+		{input: "10d10>=8", expected: Die{Sides: 10, Count: 10, SuccessOn: Compare{Op: ">=", Value: 8}}},
+		{input: "12d6>=5f1", expected: Die{Sides: 6, Count: 12, SuccessOn: Compare{Op: ">=", Value: 5}, FailOn: Compare{Op: "=", Value: 1}}},
+		{input: "5d6!kh3", expected: Die{Sides: 6, Count: 5, Keep: KeepHighest, KeepN: 3, Explode: ExplodeStandard}},
+		{input: "d20cs>=19cf1", expected: Die{Sides: 20, Count: 1, CritSuccessOn: Compare{Op: ">=", Value: 19}, CritFailOn: Compare{Op: "=", Value: 1}}},
+		{input: "2d20kh1cs20", expected: Die{Sides: 20, Count: 2, Keep: KeepHighest, KeepN: 1, CritSuccessOn: Compare{Op: "=", Value: 20}}},
+		{input: "d100b2", expected: Die{Sides: 100, Count: 1, Bonus: 2}},
+		{input: "d100p", expected: Die{Sides: 100, Count: 1, Penalty: 1}},
+		{input: "d100p1cf100", expected: Die{Sides: 100, Count: 1, Penalty: 1, CritFailOn: Compare{Op: "=", Value: 100}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := parse(tt.input, Options{})
+			if err != nil {
+				t.Fatalf("parse(%s) returned error %v", tt.input, err)
+			}
+			dice, ok := result.(*diceNode)
+			if !ok || !reflect.DeepEqual(dice.die, tt.expected) {
+				t.Fatalf("parse(%s) = %+v; want %+v", tt.input, result, tt.expected)
+			}
+			again, err := parse(dice.String(), Options{})
+			if err != nil || !reflect.DeepEqual(again.(*diceNode).die, tt.expected) {
+				t.Errorf("parse(%s) doesn't round trip through %q", tt.input, dice.String())
+			}
+		})
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	_, err := RollDice(&mockRand{value: 3}, "1d6/(2-2)", nil)
+	if err == nil || err.Error() != "division by zero" {
+		t.Errorf("RollDice error = %v; want division by zero", err)
+	}
+}
+
+func TestParseFaceSets(t *testing.T) {
+	tests := []struct {
+		input    string
+		sides    int
+		values   []int
+		labels   []string
+		expected string
+	}{
+		{input: "4dF", sides: 3, values: []int{-1, 0, 1}, expected: "4dF"},
+		{input: "4dFkh2", sides: 3, values: []int{-1, 0, 1}, expected: "4dFkh2"},
+		{input: "d{-1, 0, 0, 1, 2, 3}", sides: 6, values: []int{-1, 0, 0, 1, 2, 3}, expected: "1d{-1,0,0,1,2,3}"},
+		{input: "2d{hit,miss,crit}", sides: 3, labels: []string{"hit", "miss", "crit"}, expected: "2d{hit,miss,crit}"},
+		{input: "d{1,miss}", sides: 2, labels: []string{"1", "miss"}, expected: "1d{1,miss}"},
+		{input: "2d66", sides: 36, values: []int{
+			11, 12, 13, 14, 15, 16, 21, 22, 23, 24, 25, 26, 31, 32, 33, 34, 35, 36,
+			41, 42, 43, 44, 45, 46, 51, 52, 53, 54, 55, 56, 61, 62, 63, 64, 65, 66,
+		}, expected: "2d66"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := parse(tt.input, Options{})
+			if err != nil {
+				t.Fatalf("parse(%s) returned error %v", tt.input, err)
+			}
+			die := result.(*diceNode).die
+			if die.Sides != tt.sides || !slices.Equal(die.Faces.Values, tt.values) || !slices.Equal(die.Faces.Labels, tt.labels) {
+				t.Errorf("parse(%s) = %+v with faces %+v", tt.input, die, die.Faces)
+			}
+			if die.String() != tt.expected {
+				t.Errorf("parse(%s).String() = %q; want %q", tt.input, die.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestMacroExpressions(t *testing.T) {
+	macros := map[string]string{
+		"longsword": "1d20+7",
+		"damage":    "1d8+4",
+		"smite":     "@damage+2d8",
+		"loop":      "@loop+1",
+		"broken":    "1d6+",
+	}
+	tests := []struct {
+		expression string
+		total      int
+		breakdown  string
+	}{
+		{"@longsword", 8, "(1 + 7)"},
+		{"@LongSword", 8, "(1 + 7)"},
+		{"@damage+1d6", 6, "(1 + 4) + 1"},
+		{"@smite*2", 14, "((1 + 4) + 2) * 2"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.expression, Options{Macros: macros})
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.expression, err)
+		}
+		result, err := e.Roll(&mockRand{value: 0})
+		if err != nil {
+			t.Fatalf("Roll(%q) returned error: %v", tt.expression, err)
+		}
+		if result.Total != tt.total || result.Breakdown != tt.breakdown {
+			t.Errorf("%q = %d (%s); want %d (%s)", tt.expression, result.Total, result.Breakdown, tt.total, tt.breakdown)
+		}
+	}
+
+	errorTests := []struct {
+		expression string
+		msg        string
+	}{
+		{"@missing", "unknown macro @missing"},
+		{"1+@loop", "macro @loop refers to itself"},
+		{"@broken", "in macro @broken (1d6+)"},
+		{"@", "expected a macro name"},
+	}
+	for _, tt := range errorTests {
+		_, err := Parse(tt.expression, Options{Macros: macros})
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("Parse(%q) error = %v; want it to mention %q", tt.expression, err, tt.msg)
+		}
+	}
+}
+
+func TestMacroStats(t *testing.T) {
+	e, err := Parse("@hit", Options{Macros: map[string]string{"hit": "1d4"}})
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	dist, err := e.Distribution()
+	if err != nil {
+		t.Fatalf("Distribution returned error: %v", err)
+	}
+	if !almostEqual(dist.Mean(), 2.5) {
+		t.Errorf("mean of @hit = %f; want 2.5", dist.Mean())
+	}
+}
+
+func TestCheckMacroName(t *testing.T) {
+	for _, name := range []string{"longsword", "sneak_attack", "fireball3"} {
+		if err := CheckMacroName(name); err != nil {
+			t.Errorf("CheckMacroName(%q) returned error: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "3rd", "long-sword", "a b"} {
+		if err := CheckMacroName(name); err == nil {
+			t.Errorf("CheckMacroName(%q) expected an error", name)
+		}
+	}
+}
+
+func TestRollDiceWithVars(t *testing.T) {
+	vars := Vars{"str": 3, "prof": 2, "skills.stealth": -1}
+	tests := []struct {
+		input     string
+		total     int
+		breakdown string
+	}{
+		{"1d20+$str+$prof", 10, "5 + $str(3) + $prof(2)"},
+		{"1d20+$STR", 8, "5 + $str(3)"},
+		{"1d20+$skills.stealth", 4, "5 + $skills.stealth(-1)"},
+		{"$prof*(1d6)", 10, "$prof(2) * (5)"},
+	}
+	for _, tt := range tests {
+		result, err := RollDice(&mockRand{value: 4}, tt.input, vars)
+		if err != nil {
+			t.Fatalf("RollDice(%s) returned error: %v", tt.input, err)
+		}
+		if result.Total != tt.total || result.Breakdown != tt.breakdown {
+			t.Errorf("RollDice(%s) = %d (%s); want %d (%s)", tt.input, result.Total, result.Breakdown, tt.total, tt.breakdown)
+		}
+	}
+
+	for _, input := range []string{"1d20+$dex", "1d20+$", "1d20+$str"} {
+		useVars := vars
+		if input == "1d20+$str" {
+			useVars = nil
+		}
+		if _, err := RollDice(&mockRand{value: 4}, input, useVars); err == nil {
+			t.Errorf("RollDice(%s) expected an error", input)
+		}
+	}
+}
+
+func TestVarsInMacrosAndStats(t *testing.T) {
+	opts := Options{Macros: map[string]string{"attack": "1d20+$str"}, Vars: Vars{"str": 3}}
+	e, err := Parse("@attack", opts)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	dist, err := e.Distribution()
+	if err != nil {
+		t.Fatalf("Distribution returned error: %v", err)
+	}
+	if !almostEqual(dist.Mean(), 13.5) {
+		t.Errorf("mean of @attack = %f; want 13.5", dist.Mean())
+	}
+
+	if _, err := Parse("1d20+$anything", Options{AnyVar: true}); err != nil {
+		t.Errorf("Parse with AnyVar returned error: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FaceSet describes the faces of a die that isn't numbered 1 to Sides. A
+// numeric face set has a Value for each face; a symbolic one has a Label for
+// each face and its dice are tallied rather than summed.
+type FaceSet struct {
+	// Name is a shorthand like "F" used instead of listing the faces.
+	Name   string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Values []int    `json:"values,omitempty" yaml:"values,omitempty"`
+	Labels []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// DigitSides is set for positional dice such as a d66, where each digit
+	// of the face is rolled on its own die with this many sides.
+	DigitSides int `json:"digit_sides,omitempty" yaml:"digit_sides,omitempty"`
+}
+
+// fateFaces are the faces of a Fudge/Fate die.
+var fateFaces = &FaceSet{Name: "F", Values: []int{-1, 0, 1}}
+
+// positionalFaces returns the faces of a positional die written like "66"
+// or "666", where each 6 is a d6 read as one digit of the result. It returns
+// nil for any other number of sides.
+func positionalFaces(sides string) *FaceSet {
+	if sides != "66" && sides != "666" {
+		return nil
+	}
+	faces := &FaceSet{Name: sides, Values: []int{0}, DigitSides: 6}
+	for range sides {
+		var values []int
+		for _, v := range faces.Values {
+			for digit := 1; digit <= faces.DigitSides; digit++ {
+				values = append(values, v*10+digit)
+			}
+		}
+		faces.Values = values
+	}
+	return faces
+}
+
+// parseFaceSet parses the faces listed between the braces of d{...}. If
+// every face is a number the set is numeric, otherwise it's symbolic.
+func parseFaceSet(list string) (*FaceSet, error) {
+	var faces FaceSet
+	numeric := true
+	for _, face := range strings.Split(list, ",") {
+		face = strings.TrimSpace(face)
+		if face == "" {
+			return nil, fmt.Errorf("empty face")
+		}
+		faces.Labels = append(faces.Labels, face)
+		if value, err := strconv.Atoi(face); err == nil {
+			faces.Values = append(faces.Values, value)
+		} else {
+			numeric = false
+		}
+	}
+	if len(faces.Labels) < 2 {
+		return nil, fmt.Errorf("dice must have at least 2 faces")
+	}
+	if numeric {
+		faces.Labels = nil
+	} else {
+		faces.Values = nil
+	}
+	return &faces, nil
+}
+
+func (f *FaceSet) symbolic() bool {
+	return f.Labels != nil
+}
+
+func (f *FaceSet) size() int {
+	if f.symbolic() {
+		return len(f.Labels)
+	}
+	return len(f.Values)
+}
+
+func (f *FaceSet) String() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	if f.symbolic() {
+		return "{" + strings.Join(f.Labels, ",") + "}"
+	}
+	values := make([]string, len(f.Values))
+	for i, v := range f.Values {
+		values[i] = strconv.Itoa(v)
+	}
+	return "{" + strings.Join(values, ",") + "}"
+}
+
+// FaceCount is how many times a symbolic face came up.
+type FaceCount struct {
+	Face  string `json:"face" yaml:"face"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+// tallyFaces counts the kept symbolic dice in rolls, listing faces in the
+// order they're defined.
+func tallyFaces(faces *FaceSet, rolls []DieRoll) []FaceCount {
+	var tally []FaceCount
+	for _, label := range faces.Labels {
+		count := 0
+		for _, roll := range rolls {
+			if !roll.Dropped && roll.Face == label {
+				count++
+			}
+		}
+		if count > 0 && !containsFace(tally, label) {
+			tally = append(tally, FaceCount{Face: label, Count: count})
+		}
+	}
+	return tally
+}
+
+// MergeTally adds the counts in more to tally, appending faces it hasn't
+// seen yet.
+func MergeTally(tally, more []FaceCount) []FaceCount {
+	for _, fc := range more {
+		found := false
+		for i := range tally {
+			if tally[i].Face == fc.Face {
+				tally[i].Count += fc.Count
+				found = true
+			}
+		}
+		if !found {
+			tally = append(tally, fc)
+		}
+	}
+	return tally
+}
+
+func containsFace(tally []FaceCount, face string) bool {
+	for _, fc := range tally {
+		if fc.Face == face {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatTally writes a tally as a list such as "2 hit, 1 miss".
+func FormatTally(tally []FaceCount) string {
+	if len(tally) == 0 {
+		return "nothing"
+	}
+	parts := make([]string, len(tally))
+	for i, fc := range tally {
+		parts[i] = fmt.Sprintf("%d %s", fc.Count, fc.Face)
+	}
+	return strings.Join(parts, ", ")
+}
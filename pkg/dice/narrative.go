@@ -0,0 +1,227 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Symbol is one of the symbols printed on narrative dice, as used by
+// Genesys and the Star Wars roleplaying games.
+type Symbol string
+
+const (
+	Success   Symbol = "success"
+	Failure   Symbol = "failure"
+	Advantage Symbol = "advantage"
+	Threat    Symbol = "threat"
+	// Triumph counts as a success that can't be cancelled.
+	Triumph Symbol = "triumph"
+	// Despair counts as a failure that can't be cancelled.
+	Despair Symbol = "despair"
+	// Light and Dark are the Force points on a Star Wars force die.
+	Light Symbol = "light"
+	Dark  Symbol = "dark"
+)
+
+// NarrativeDie is a kind of narrative die and the symbols on each of its
+// faces. Blank faces have no symbols.
+type NarrativeDie struct {
+	Name  string
+	Color string
+	Faces [][]Symbol
+}
+
+// NarrativeDice are the narrative dice, keyed by the letter of their
+// colour used in a pool such as "2g1y2p1r".
+var NarrativeDice = narrativeDice()
+
+func narrativeDice() map[rune]*NarrativeDie {
+	blank := []Symbol{}
+	s := []Symbol{Success}
+	f := []Symbol{Failure}
+	a := []Symbol{Advantage}
+	t := []Symbol{Threat}
+	ss := []Symbol{Success, Success}
+	ff := []Symbol{Failure, Failure}
+	aa := []Symbol{Advantage, Advantage}
+	tt := []Symbol{Threat, Threat}
+	sa := []Symbol{Success, Advantage}
+	ft := []Symbol{Failure, Threat}
+	return map[rune]*NarrativeDie{
+		'b': {Name: "boost", Color: "blue", Faces: [][]Symbol{blank, blank, s, sa, aa, a}},
+		'k': {Name: "setback", Color: "black", Faces: [][]Symbol{blank, blank, f, f, t, t}},
+		'g': {Name: "ability", Color: "green", Faces: [][]Symbol{blank, s, s, ss, a, a, sa, aa}},
+		'p': {Name: "difficulty", Color: "purple", Faces: [][]Symbol{blank, f, ff, t, t, t, tt, ft}},
+		'y': {Name: "proficiency", Color: "yellow", Faces: [][]Symbol{blank, s, s, ss, ss, a, sa, sa, sa, aa, aa, {Triumph}}},
+		'r': {Name: "challenge", Color: "red", Faces: [][]Symbol{blank, f, f, ff, ff, t, t, ft, ft, tt, tt, {Despair}}},
+		'w': {Name: "force", Color: "white", Faces: [][]Symbol{
+			{Dark}, {Dark}, {Dark}, {Dark}, {Dark}, {Dark}, {Dark, Dark},
+			{Light}, {Light}, {Light, Light}, {Light, Light}, {Light, Light},
+		}},
+	}
+}
+
+// SymbolCounts counts each symbol in a narrative roll.
+type SymbolCounts struct {
+	Success   int `json:"success,omitempty" yaml:"success,omitempty"`
+	Failure   int `json:"failure,omitempty" yaml:"failure,omitempty"`
+	Advantage int `json:"advantage,omitempty" yaml:"advantage,omitempty"`
+	Threat    int `json:"threat,omitempty" yaml:"threat,omitempty"`
+	Triumph   int `json:"triumph,omitempty" yaml:"triumph,omitempty"`
+	Despair   int `json:"despair,omitempty" yaml:"despair,omitempty"`
+	Light     int `json:"light,omitempty" yaml:"light,omitempty"`
+	Dark      int `json:"dark,omitempty" yaml:"dark,omitempty"`
+}
+
+func (c *SymbolCounts) add(symbol Symbol) {
+	switch symbol {
+	case Success:
+		c.Success++
+	case Failure:
+		c.Failure++
+	case Advantage:
+		c.Advantage++
+	case Threat:
+		c.Threat++
+	case Triumph:
+		c.Triumph++
+	case Despair:
+		c.Despair++
+	case Light:
+		c.Light++
+	case Dark:
+		c.Dark++
+	}
+}
+
+// cancel works out what's left of a roll once each failure has cancelled a
+// success and each threat an advantage. Triumphs and despairs also count as
+// a success and a failure, but are still reported themselves.
+func (c SymbolCounts) cancel() SymbolCounts {
+	net := SymbolCounts{Triumph: c.Triumph, Despair: c.Despair, Light: c.Light, Dark: c.Dark}
+	if n := c.Success + c.Triumph - c.Failure - c.Despair; n > 0 {
+		net.Success = n
+	} else {
+		net.Failure = -n
+	}
+	if n := c.Advantage - c.Threat; n > 0 {
+		net.Advantage = n
+	} else {
+		net.Threat = -n
+	}
+	return net
+}
+
+// NarrativeRoll is a single narrative die and the face it rolled.
+type NarrativeRoll struct {
+	Die  string   `json:"die" yaml:"die"`
+	Face []Symbol `json:"face,omitempty" yaml:"face,omitempty"`
+}
+
+// NarrativeResult is the outcome of a pool of narrative dice. Rolled counts
+// every symbol that came up, and Net is what's left after they cancel. The
+// check succeeds if at least one success is left.
+type NarrativeResult struct {
+	Pool      string          `json:"pool" yaml:"pool"`
+	Rolls     []NarrativeRoll `json:"rolls" yaml:"rolls"`
+	Rolled    SymbolCounts    `json:"rolled" yaml:"rolled"`
+	Net       SymbolCounts    `json:"net" yaml:"net"`
+	Succeeded bool            `json:"succeeded" yaml:"succeeded"`
+}
+
+// ParseNarrativePool parses a pool of narrative dice such as "2g1y2p1r":
+// a count, which defaults to 1, before the colour letter of each kind of
+// die in NarrativeDice. Groups may be separated by spaces or "+".
+func ParseNarrativePool(pool string) ([]*NarrativeDie, error) {
+	var dice []*NarrativeDie
+	for i := 0; i < len(pool); {
+		c := rune(pool[i])
+		if unicode.IsSpace(c) || c == '+' {
+			i++
+			continue
+		}
+		start := i
+		for i < len(pool) && unicode.IsDigit(rune(pool[i])) {
+			i++
+		}
+		count := 1
+		if i > start {
+			var err error
+			count, err = strconv.Atoi(pool[start:i])
+			if err != nil || count < 1 || count > maxDiceCount {
+				return nil, &ParseError{Expression: pool, Pos: start, Msg: fmt.Sprintf("dice count must be between 1 and %d", maxDiceCount)}
+			}
+		}
+		if i == len(pool) {
+			return nil, &ParseError{Expression: pool, Pos: i, Msg: "expected a die colour after the count"}
+		}
+		die, ok := NarrativeDice[unicode.ToLower(rune(pool[i]))]
+		if !ok {
+			return nil, &ParseError{Expression: pool, Pos: i, Msg: fmt.Sprintf("unknown narrative die %q: use %s", pool[i], narrativeLetters())}
+		}
+		i++
+		for range count {
+			dice = append(dice, die)
+		}
+	}
+	if len(dice) == 0 {
+		return nil, &ParseError{Expression: pool, Pos: 0, Msg: "empty dice pool"}
+	}
+	return dice, nil
+}
+
+// narrativeLetters lists the colour letters for error messages.
+func narrativeLetters() string {
+	var letters []string
+	for _, c := range "bkgpyrw" {
+		letters = append(letters, fmt.Sprintf("%c (%s)", c, NarrativeDice[c].Name))
+	}
+	return strings.Join(letters, ", ")
+}
+
+// RollNarrative rolls a pool of narrative dice and cancels their symbols.
+func RollNarrative(r RandIntn, pool string) (NarrativeResult, error) {
+	dice, err := ParseNarrativePool(pool)
+	if err != nil {
+		return NarrativeResult{}, err
+	}
+	result := NarrativeResult{Pool: pool}
+	for _, die := range dice {
+		n, err := rollOne(len(die.Faces), r)
+		if err != nil {
+			return NarrativeResult{}, err
+		}
+		face := die.Faces[n-1]
+		for _, symbol := range face {
+			result.Rolled.add(symbol)
+		}
+		result.Rolls = append(result.Rolls, NarrativeRoll{Die: die.Name, Face: face})
+	}
+	result.Net = result.Rolled.cancel()
+	result.Succeeded = result.Net.Success > 0
+	return result, nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNarrativeDiceFaces(t *testing.T) {
+	tests := []struct {
+		letter rune
+		sides  int
+		total  SymbolCounts
+	}{
+		{'b', 6, SymbolCounts{Success: 2, Advantage: 4}},
+		{'k', 6, SymbolCounts{Failure: 2, Threat: 2}},
+		{'g', 8, SymbolCounts{Success: 5, Advantage: 5}},
+		{'p', 8, SymbolCounts{Failure: 4, Threat: 6}},
+		{'y', 12, SymbolCounts{Success: 9, Advantage: 8, Triumph: 1}},
+		{'r', 12, SymbolCounts{Failure: 8, Threat: 8, Despair: 1}},
+		{'w', 12, SymbolCounts{Light: 8, Dark: 8}},
+	}
+	for _, tt := range tests {
+		die := NarrativeDice[tt.letter]
+		if len(die.Faces) != tt.sides {
+			t.Errorf("%s die has %d faces; want %d", die.Name, len(die.Faces), tt.sides)
+		}
+		var total SymbolCounts
+		for _, face := range die.Faces {
+			for _, symbol := range face {
+				total.add(symbol)
+			}
+		}
+		if total != tt.total {
+			t.Errorf("%s die has symbols %+v; want %+v", die.Name, total, tt.total)
+		}
+	}
+}
+
+func TestParseNarrativePool(t *testing.T) {
+	tests := []struct {
+		pool     string
+		expected []string
+	}{
+		{"2g1y2p1r", []string{"ability", "ability", "proficiency", "difficulty", "difficulty", "challenge"}},
+		{"g p", []string{"ability", "difficulty"}},
+		{"1B+1K+w", []string{"boost", "setback", "force"}},
+	}
+	for _, tt := range tests {
+		dice, err := ParseNarrativePool(tt.pool)
+		if err != nil {
+			t.Errorf("ParseNarrativePool(%q) returned error: %v", tt.pool, err)
+			continue
+		}
+		var names []string
+		for _, die := range dice {
+			names = append(names, die.Name)
+		}
+		if len(names) != len(tt.expected) {
+			t.Errorf("ParseNarrativePool(%q) = %v; want %v", tt.pool, names, tt.expected)
+			continue
+		}
+		for i := range names {
+			if names[i] != tt.expected[i] {
+				t.Errorf("ParseNarrativePool(%q) = %v; want %v", tt.pool, names, tt.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestParseNarrativePoolErrors(t *testing.T) {
+	tests := []struct {
+		pool string
+		pos  int
+	}{
+		{"", 0},
+		{"2g3", 3},
+		{"2g1x", 3},
+		{"0g", 0},
+	}
+	for _, tt := range tests {
+		_, err := ParseNarrativePool(tt.pool)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("ParseNarrativePool(%q) error = %v; want a ParseError", tt.pool, err)
+			continue
+		}
+		if perr.Pos != tt.pos {
+			t.Errorf("ParseNarrativePool(%q) error at %d; want %d", tt.pool, perr.Pos, tt.pos)
+		}
+	}
+}
+
+func TestRollNarrativeCancelsSymbols(t *testing.T) {
+	// ability: success+advantage, proficiency: triumph,
+	// difficulty: threat+threat, challenge: failure+failure.
+	r := &seqRand{values: []int{6, 11, 6, 3}}
+	result, err := RollNarrative(r, "gypr")
+	if err != nil {
+		t.Fatalf("RollNarrative returned error: %v", err)
+	}
+	rolled := SymbolCounts{Success: 1, Failure: 2, Advantage: 1, Threat: 2, Triumph: 1}
+	if result.Rolled != rolled {
+		t.Errorf("Rolled = %+v; want %+v", result.Rolled, rolled)
+	}
+	net := SymbolCounts{Threat: 1, Triumph: 1}
+	if result.Net != net {
+		t.Errorf("Net = %+v; want %+v", result.Net, net)
+	}
+	if result.Succeeded {
+		t.Error("Expected the check to fail when successes and failures cancel out")
+	}
+}
+
+func TestRollNarrativeTriumphSucceeds(t *testing.T) {
+	r := &seqRand{values: []int{11, 11}}
+	result, err := RollNarrative(r, "yr")
+	if err != nil {
+		t.Fatalf("RollNarrative returned error: %v", err)
+	}
+	net := SymbolCounts{Triumph: 1, Despair: 1}
+	if result.Net != net {
+		t.Errorf("Net = %+v; want %+v", result.Net, net)
+	}
+	if result.Succeeded {
+		t.Error("Expected a triumph cancelled by a despair not to succeed")
+	}
+
+	r = &seqRand{values: []int{11}}
+	result, err = RollNarrative(r, "y")
+	if err != nil {
+		t.Fatalf("RollNarrative returned error: %v", err)
+	}
+	if result.Net.Success != 1 || !result.Succeeded {
+		t.Errorf("Expected a lone triumph to leave a success, got %+v", result.Net)
+	}
+}
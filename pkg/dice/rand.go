@@ -0,0 +1,94 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+)
+
+// SeededRand is a reproducible random source. Its output for a given seed
+// is a contract: the same seed and expression must always produce the same
+// result, on every platform and in every release, so that a roll can be
+// replayed later.
+//
+// It's SplitMix64, with Intn using rejection sampling so that every value
+// below n is equally likely. Don't change either algorithm; the golden
+// values in rand_test.go exist to catch that.
+type SeededRand struct {
+	state uint64
+}
+
+// NewSeededRand returns a SeededRand starting from seed.
+func NewSeededRand(seed int64) *SeededRand {
+	return &SeededRand{state: uint64(seed)}
+}
+
+// Uint64 returns the next value in the sequence.
+func (r *SeededRand) Uint64() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Intn returns a uniformly distributed value in [0, n). It panics if n <= 0.
+func (r *SeededRand) Intn(n int) int {
+	return uniformIntn(r.Uint64, n)
+}
+
+// uniformIntn turns a source of random 64-bit values into a uniformly
+// distributed value in [0, n). It panics if n <= 0.
+func uniformIntn(next func() uint64, n int) int {
+	if n <= 0 {
+		panic("invalid argument to Intn")
+	}
+	bound := uint64(n)
+	// Values below threshold would make the low results slightly more
+	// likely, so they're thrown away.
+	threshold := -bound % bound
+	for {
+		if v := next(); v >= threshold {
+			return int(v % bound)
+		}
+	}
+}
+
+// CryptoRand draws from crypto/rand for rolls where fairness matters more
+// than being able to replay them.
+type CryptoRand struct{}
+
+// Uint64 returns a random value from crypto/rand.
+func (CryptoRand) Uint64() uint64 {
+	var buf [8]byte
+	// crypto/rand.Read never returns an error; it crashes the program if
+	// the operating system can't provide randomness.
+	cryptorand.Read(buf[:])
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+// Intn returns a uniformly distributed value in [0, n). It panics if n <= 0.
+func (r CryptoRand) Intn(n int) int {
+	return uniformIntn(r.Uint64, n)
+}
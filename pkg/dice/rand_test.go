@@ -0,0 +1,82 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"slices"
+	"testing"
+)
+
+// These golden values pin down the seeded random source. If they change,
+// seeded rolls can no longer be replayed.
+
+func TestSeededRandMatchesSplitMix64(t *testing.T) {
+	if got := NewSeededRand(0).Uint64(); got != 16294208416658607535 {
+		t.Errorf("first value for seed 0 = %d; want 16294208416658607535", got)
+	}
+
+	r := NewSeededRand(42)
+	expected := []uint64{13679457532755275413, 2949826092126892291, 5139283748462763858}
+	for i, want := range expected {
+		if got := r.Uint64(); got != want {
+			t.Errorf("value %d for seed 42 = %d; want %d", i, got, want)
+		}
+	}
+}
+
+func TestSeededRandIntn(t *testing.T) {
+	r := NewSeededRand(42)
+	var got []int
+	for i := 0; i < 8; i++ {
+		got = append(got, r.Intn(20))
+	}
+	expected := []int{13, 11, 18, 4, 10, 2, 5, 8}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Intn(20) for seed 42 = %v; want %v", got, expected)
+	}
+}
+
+func TestSameSeedSameRoll(t *testing.T) {
+	result, err := RollDice(NewSeededRand(1234), "4d6kh3+1d20", nil)
+	if err != nil {
+		t.Fatalf("RollDice returned error %v", err)
+	}
+	if result.Total != 22 || result.Breakdown != "12 + 10" {
+		t.Errorf("RollDice with seed 1234 = %d (%s); want 22 (12 + 10)", result.Total, result.Breakdown)
+	}
+}
+
+func TestCryptoRand(t *testing.T) {
+	var r CryptoRand
+	seen := make([]bool, 6)
+	for i := 0; i < 1000; i++ {
+		v := r.Intn(6)
+		if v < 0 || v >= 6 {
+			t.Fatalf("CryptoRand.Intn(6) = %d; want a value in [0, 6)", v)
+		}
+		seen[v] = true
+	}
+	if slices.Contains(seen, false) {
+		t.Errorf("CryptoRand.Intn(6) never produced some values in 1000 tries: %v", seen)
+	}
+}
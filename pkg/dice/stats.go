@@ -0,0 +1,384 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"errors"
+	"math"
+	"slices"
+)
+
+// maxExactOutcomes bounds the work done computing a distribution exactly
+// before giving up with ErrInexact.
+const maxExactOutcomes = 5000000
+
+// ErrInexact means an expression's distribution can't be computed exactly,
+// either because it's unbounded (exploding dice) or too large.
+var ErrInexact = errors.New("distribution can't be computed exactly")
+
+// errSymbolicStats is returned for symbolic dice, which have no totals to
+// take a distribution of.
+var errSymbolicStats = errors.New("stats aren't available for symbolic dice")
+
+// Distribution maps each possible result of an expression to its
+// probability.
+type Distribution map[int]float64
+
+// Values lists every possible result in ascending order.
+func (d Distribution) Values() []int {
+	values := make([]int, 0, len(d))
+	for v := range d {
+		values = append(values, v)
+	}
+	slices.Sort(values)
+	return values
+}
+
+// Mean is the expected result.
+func (d Distribution) Mean() float64 {
+	var mean float64
+	for v, p := range d {
+		mean += float64(v) * p
+	}
+	return mean
+}
+
+// StdDev is the standard deviation of the results.
+func (d Distribution) StdDev() float64 {
+	mean := d.Mean()
+	var variance float64
+	for v, p := range d {
+		variance += (float64(v) - mean) * (float64(v) - mean) * p
+	}
+	return math.Sqrt(variance)
+}
+
+// AtLeast is the probability of rolling n or more.
+func (d Distribution) AtLeast(n int) float64 {
+	var total float64
+	for v, p := range d {
+		if v >= n {
+			total += p
+		}
+	}
+	return total
+}
+
+// Between is the probability of a result from lo to hi inclusive.
+func (d Distribution) Between(lo, hi int) float64 {
+	var total float64
+	for v, p := range d {
+		if v >= lo && v <= hi {
+			total += p
+		}
+	}
+	return total
+}
+
+// Distribution works out the exact chance of each total the expression can
+// roll. It returns ErrInexact for expressions that can't be computed
+// exactly, which can be estimated with Sample instead.
+func (e *Expression) Distribution() (Distribution, error) {
+	if e.Symbolic() {
+		return nil, errSymbolicStats
+	}
+	return exactDistribution(e.root)
+}
+
+// Sample estimates the distribution of the expression by rolling it samples
+// times with r.
+func (e *Expression) Sample(r RandIntn, samples int) (Distribution, error) {
+	if e.Symbolic() {
+		return nil, errSymbolicStats
+	}
+	counts := map[int]int{}
+	for i := 0; i < samples; i++ {
+		result, err := rollTree(r, e.root)
+		if err != nil {
+			return nil, err
+		}
+		counts[result.Total]++
+	}
+	dist := Distribution{}
+	for v, n := range counts {
+		dist[v] = float64(n) / float64(samples)
+	}
+	return dist, nil
+}
+
+// exactDistribution computes the distribution of tree by convolving the
+// distributions of its parts. It returns ErrInexact for anything it can't
+// compute exactly.
+func exactDistribution(tree exprNode) (Distribution, error) {
+	switch n := tree.(type) {
+	case *numberNode:
+		return Distribution{n.value: 1}, nil
+	case *varNode:
+		return Distribution{n.value: 1}, nil
+	case *groupNode:
+		return exactDistribution(n.inner)
+	case *macroNode:
+		return exactDistribution(n.inner)
+	case *negateNode:
+		inner, err := exactDistribution(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		dist := Distribution{}
+		for v, p := range inner {
+			dist[-v] += p
+		}
+		return dist, nil
+	case *binaryNode:
+		left, err := exactDistribution(n.left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := exactDistribution(n.right)
+		if err != nil {
+			return nil, err
+		}
+		return combine(n.op, left, right)
+	case *diceNode:
+		return diceDistribution(&n.die)
+	}
+	return nil, ErrInexact
+}
+
+// combine applies op to every pair of outcomes from two independent
+// distributions.
+func combine(op byte, left, right Distribution) (Distribution, error) {
+	if len(left)*len(right) > maxExactOutcomes {
+		return nil, ErrInexact
+	}
+	dist := Distribution{}
+	for a, pa := range left {
+		for b, pb := range right {
+			v, err := applyOperator(op, a, b)
+			if err != nil {
+				return nil, err
+			}
+			dist[v] += pa * pb
+		}
+	}
+	return dist, nil
+}
+
+// faceDistribution is the distribution of a single die of the term,
+// including the effect of any reroll or minimum modifiers.
+func faceDistribution(die *Die) Distribution {
+	dist := Distribution{}
+	if die.Bonus > 0 || die.Penalty > 0 {
+		dist = percentileDistribution(die)
+	} else {
+		for _, v := range die.faceValues() {
+			dist[v] += 1 / float64(die.Sides)
+		}
+	}
+
+	var matching float64
+	for v, p := range dist {
+		if die.RerollOn.Op != "" && die.RerollOn.Match(v) {
+			matching += p
+		}
+	}
+	switch die.Reroll {
+	case RerollOnce:
+		// A matching first roll is replaced by a fresh roll of the die.
+		rerolled := Distribution{}
+		for v, p := range dist {
+			if !die.RerollOn.Match(v) {
+				rerolled[v] += p
+			}
+			rerolled[v] += matching * p
+		}
+		dist = rerolled
+	case RerollUntil:
+		// Matching faces never stick, so the rest share their probability.
+		rerolled := Distribution{}
+		for v, p := range dist {
+			if !die.RerollOn.Match(v) {
+				rerolled[v] = p / (1 - matching)
+			}
+		}
+		dist = rerolled
+	}
+
+	if die.Min != nil {
+		floored := Distribution{}
+		for v, p := range dist {
+			floored[max(v, *die.Min)] += p
+		}
+		dist = floored
+	}
+	return dist
+}
+
+// percentileDistribution is the distribution of a d100 rolled with bonus or
+// penalty dice. For each ones digit, the tens dice give ten distinct
+// results, and the chance the best of them is the ith lowest follows from
+// the chance that every die lands at or beyond it.
+func percentileDistribution(die *Die) Distribution {
+	count := float64(1 + die.Bonus + die.Penalty)
+	dist := Distribution{}
+	for ones := 0; ones < 10; ones++ {
+		values := make([]int, 10)
+		for tens := range values {
+			values[tens] = percentileValue(tens, ones)
+		}
+		slices.Sort(values)
+		for i, v := range values {
+			var p float64
+			if die.Bonus > 0 {
+				p = math.Pow(float64(10-i)/10, count) - math.Pow(float64(9-i)/10, count)
+			} else {
+				p = math.Pow(float64(i+1)/10, count) - math.Pow(float64(i)/10, count)
+			}
+			dist[v] += p / 10
+		}
+	}
+	return dist
+}
+
+// diceDistribution is the distribution of a dice term's total, or of its
+// number of successes for a success pool.
+func diceDistribution(die *Die) (Distribution, error) {
+	// A die with more sides than the bound would take as long just to list
+	// its faces.
+	if die.Explode != ExplodeNone || die.Sides > maxExactOutcomes {
+		return nil, ErrInexact
+	}
+	faces := faceDistribution(die)
+	if die.Keep != KeepAll {
+		return keptDistribution(die, faces)
+	}
+
+	single := faces
+	if die.countsSuccesses() {
+		single = Distribution{}
+		for v, p := range faces {
+			single[successValue(die, v)] += p
+		}
+	}
+	// Each die is convolved in turn, so the work adds up over the whole pool
+	// and is bounded as a whole, not just step by step.
+	dist := Distribution{0: 1}
+	work := 0
+	for i := 0; i < die.Count; i++ {
+		work += len(dist) * len(single)
+		if work > maxExactOutcomes {
+			return nil, ErrInexact
+		}
+		var err error
+		if dist, err = combine('+', dist, single); err != nil {
+			return nil, err
+		}
+	}
+	return dist, nil
+}
+
+func successValue(die *Die, v int) int {
+	if die.SuccessOn.Match(v) {
+		return 1
+	}
+	return 0
+}
+
+// keptDistribution handles keep and drop modifiers by walking every
+// combination of faces the dice could show. Order doesn't matter for which
+// dice are kept, so each multiset of faces is visited once and weighted by
+// the number of ways it can be rolled.
+func keptDistribution(die *Die, faces Distribution) (Distribution, error) {
+	values := faces.Values()
+	if binomial(len(values)+die.Count-1, die.Count) > maxExactOutcomes {
+		return nil, ErrInexact
+	}
+
+	// Pick dice from the highest face down so each multiset comes out
+	// already sorted highest first.
+	slices.Reverse(values)
+	dist := Distribution{}
+	picked := make([]int, 0, die.Count)
+	var walk func(start int, p float64, remaining int)
+	walk = func(start int, p float64, remaining int) {
+		if remaining == 0 {
+			dist[keptTotal(die, picked)] += p * multinomial(picked)
+			return
+		}
+		for i := start; i < len(values); i++ {
+			picked = append(picked, values[i])
+			walk(i, p*faces[values[i]], remaining-1)
+			picked = picked[:len(picked)-1]
+		}
+	}
+	walk(0, 1, die.Count)
+	return dist, nil
+}
+
+// keptTotal totals the dice kept from sorted, which is ordered highest
+// first.
+func keptTotal(die *Die, sorted []int) int {
+	var kept []int
+	switch die.Keep {
+	case KeepHighest:
+		kept = sorted[:die.KeepN]
+	case KeepLowest:
+		kept = sorted[len(sorted)-die.KeepN:]
+	case DropHighest:
+		kept = sorted[die.KeepN:]
+	case DropLowest:
+		kept = sorted[:len(sorted)-die.KeepN]
+	}
+	total := 0
+	for _, v := range kept {
+		if die.countsSuccesses() {
+			total += successValue(die, v)
+		} else {
+			total += v
+		}
+	}
+	return total
+}
+
+// multinomial is the number of orderings of the sorted values in picked.
+func multinomial(picked []int) float64 {
+	ways := 1.0
+	run := 0
+	for i := range picked {
+		if i > 0 && picked[i] == picked[i-1] {
+			run++
+		} else {
+			run = 1
+		}
+		ways *= float64(i+1) / float64(run)
+	}
+	return ways
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
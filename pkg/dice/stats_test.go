@@ -0,0 +1,196 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestExactDistribution(t *testing.T) {
+	tests := []struct {
+		input  string
+		mean   float64
+		value  int
+		chance float64
+	}{
+		{input: "2d6", mean: 7, value: 12, chance: 1.0 / 36},
+		{input: "2d6+3", mean: 10, value: 5, chance: 1.0 / 36},
+		{input: "1d20-1d4", mean: 8, value: 19, chance: 1.0 / 80},
+		{input: "(1d4+1)*2", mean: 7, value: 10, chance: 0.25},
+		{input: "2d20kh1", mean: 13.825, value: 20, chance: 39.0 / 400},
+		{input: "2d20kl1", mean: 7.175, value: 1, chance: 39.0 / 400},
+		{input: "4d6dl1", mean: 12.244598765432098, value: 18, chance: 21.0 / 1296},
+		{input: "10d10>=8", mean: 3, value: 0, chance: math.Pow(0.7, 10)},
+		{input: "d20ro1", mean: 10.975, value: 1, chance: 1.0 / 400},
+		{input: "d6r<3", mean: 4.5, value: 3, chance: 0.25},
+		{input: "d6min3", mean: 4, value: 3, chance: 0.5},
+		{input: "4dF", mean: 0, value: 4, chance: 1.0 / 81},
+		{input: "d66", mean: 38.5, value: 35, chance: 1.0 / 36},
+		{input: "d100b1", mean: 34, value: 1, chance: 0.019},
+		{input: "d100p2", mean: 75.25, value: 100, chance: 0.0271},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input, Options{})
+			if err != nil {
+				t.Fatalf("Parse(%s) returned error %v", tt.input, err)
+			}
+			dist, err := e.Distribution()
+			if err != nil {
+				t.Fatalf("Distribution(%s) returned error %v", tt.input, err)
+			}
+			var total float64
+			for _, p := range dist {
+				total += p
+			}
+			if !almostEqual(total, 1) {
+				t.Errorf("Distribution(%s) sums to %f; want 1", tt.input, total)
+			}
+			if !almostEqual(dist.Mean(), tt.mean) {
+				t.Errorf("Distribution(%s) mean = %f; want %f", tt.input, dist.Mean(), tt.mean)
+			}
+			if !almostEqual(dist[tt.value], tt.chance) {
+				t.Errorf("Distribution(%s)[%d] = %f; want %f", tt.input, tt.value, dist[tt.value], tt.chance)
+			}
+		})
+	}
+}
+
+// TestPercentileDistribution checks the odds of bonus and penalty dice
+// against every way the tens and ones dice can land.
+func TestPercentileDistribution(t *testing.T) {
+	for _, input := range []string{"d100b1", "d100p1", "d100b2", "d100p2"} {
+		die := Die{Sides: 100, Count: 1}
+		if input[4] == 'b' {
+			die.Bonus = int(input[5] - '0')
+		} else {
+			die.Penalty = int(input[5] - '0')
+		}
+		want := Distribution{}
+		tens := make([]int, 1+die.Bonus+die.Penalty)
+		outcomes := math.Pow(10, float64(len(tens)+1))
+		for i := 0; i < int(outcomes); i++ {
+			n := i
+			for j := range tens {
+				tens[j] = n % 10
+				n /= 10
+			}
+			ones := n
+			best := percentileValue(tens[0], ones)
+			for _, t := range tens[1:] {
+				if v := percentileValue(t, ones); die.Bonus > 0 && v < best || die.Penalty > 0 && v > best {
+					best = v
+				}
+			}
+			want[best] += 1 / outcomes
+		}
+
+		got := faceDistribution(&die)
+		for v := 1; v <= 100; v++ {
+			if !almostEqual(got[v], want[v]) {
+				t.Errorf("%s chance of %d = %f; want %f", input, v, got[v], want[v])
+			}
+		}
+	}
+}
+
+func TestExplodingDiceAreNotExact(t *testing.T) {
+	e, err := Parse("1d6!+2", Options{})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	if _, err := e.Distribution(); !errors.Is(err, ErrInexact) {
+		t.Errorf("Distribution(1d6!+2) error = %v; want ErrInexact", err)
+	}
+}
+
+func TestHugePoolsAreNotExact(t *testing.T) {
+	for _, input := range []string{"3000d20", "10000d6", "d100000000", "d1000000000kh1"} {
+		e, err := Parse(input, Options{})
+		if err != nil {
+			t.Fatalf("Parse returned error %v", err)
+		}
+		start := time.Now()
+		if _, err := e.Distribution(); !errors.Is(err, ErrInexact) {
+			t.Errorf("Distribution(%s) error = %v; want ErrInexact", input, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Distribution(%s) took %v to give up", input, elapsed)
+		}
+	}
+
+	// Big pools that are still cheap stay exact.
+	e, err := Parse("100d6", Options{})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	dist, err := e.Distribution()
+	if err != nil {
+		t.Fatalf("Distribution(100d6) returned error %v", err)
+	}
+	if !almostEqual(dist.Mean(), 350) {
+		t.Errorf("Distribution(100d6) mean = %f; want 350", dist.Mean())
+	}
+}
+
+func TestSampleDistribution(t *testing.T) {
+	e, err := Parse("1d6!", Options{})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	dist, err := e.Sample(&seqRand{values: []int{0, 1, 2}}, 300)
+	if err != nil {
+		t.Fatalf("Sample returned error %v", err)
+	}
+	if !almostEqual(dist.Mean(), 2) || len(dist) != 3 {
+		t.Errorf("Sample(1d6!) = %v; want 1, 2 and 3 equally often", dist)
+	}
+}
+
+func TestSymbolicDiceHaveNoDistribution(t *testing.T) {
+	e, err := Parse("3d{hit,miss}", Options{})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	if _, err := e.Distribution(); err == nil {
+		t.Error("Distribution(3d{hit,miss}) succeeded; want error")
+	}
+}
+
+func TestDistributionSummaries(t *testing.T) {
+	dist := Distribution{1: 0.25, 2: 0.5, 3: 0.25}
+	if !almostEqual(dist.StdDev(), math.Sqrt(0.5)) {
+		t.Errorf("StdDev = %f; want %f", dist.StdDev(), math.Sqrt(0.5))
+	}
+	if !almostEqual(dist.AtLeast(2), 0.75) {
+		t.Errorf("AtLeast(2) = %f; want 0.75", dist.AtLeast(2))
+	}
+}
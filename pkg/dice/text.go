@@ -0,0 +1,111 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Modes are marshaled as text using the same notation as in expressions,
+// e.g. "kh" or "!!", so results read naturally as JSON or YAML.
+
+func (m ResultMode) MarshalText() ([]byte, error) { return marshalName(resultModeKeys, m) }
+func (m *ResultMode) UnmarshalText(text []byte) error {
+	return unmarshalName(resultModeKeys, text, m)
+}
+
+func (m KeepMode) MarshalText() ([]byte, error) { return marshalName(keepModeNames, m) }
+func (m *KeepMode) UnmarshalText(text []byte) error {
+	return unmarshalName(keepModeNames, text, m)
+}
+
+func (m ExplodeMode) MarshalText() ([]byte, error) { return marshalName(explodeModeNames, m) }
+func (m *ExplodeMode) UnmarshalText(text []byte) error {
+	return unmarshalName(explodeModeNames, text, m)
+}
+
+func (m RerollMode) MarshalText() ([]byte, error) { return marshalName(rerollModeNames, m) }
+func (m *RerollMode) UnmarshalText(text []byte) error {
+	return unmarshalName(rerollModeNames, text, m)
+}
+
+// marshalName writes v as its name. The zero value, which usually means the
+// modifier wasn't used, may have no name.
+func marshalName[T comparable](names map[T]string, v T) ([]byte, error) {
+	name, ok := names[v]
+	var zero T
+	if !ok && v != zero {
+		return nil, fmt.Errorf("unknown value %v", v)
+	}
+	return []byte(name), nil
+}
+
+func unmarshalName[T comparable](names map[T]string, text []byte, v *T) error {
+	var zero T
+	if len(text) == 0 {
+		*v = zero
+		return nil
+	}
+	for value, name := range names {
+		if name == string(text) {
+			*v = value
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown value %q", text)
+}
+
+// MarshalText writes the comparison with its operator, e.g. ">=9" or "=1".
+func (c Compare) MarshalText() ([]byte, error) {
+	if c.Op == "" {
+		return nil, nil
+	}
+	return []byte(c.Op + strconv.Itoa(c.Value)), nil
+}
+
+func (c *Compare) UnmarshalText(text []byte) error {
+	s := string(text)
+	if s == "" {
+		*c = Compare{}
+		return nil
+	}
+	// Two character operators are checked first so ">=" isn't read as ">".
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if rest, ok := strings.CutPrefix(s, op); ok {
+			value, err := strconv.Atoi(rest)
+			if err != nil {
+				return fmt.Errorf("invalid comparison %q", s)
+			}
+			*c = Compare{Op: op, Value: value}
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid comparison %q", s)
+}
+
+// IsZero reports whether the comparison is unset, so it's left out of YAML.
+func (c Compare) IsZero() bool {
+	return c.Op == ""
+}
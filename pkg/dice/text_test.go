@@ -0,0 +1,66 @@
+/*
+Copyright © 2024 Brad Dunn <brad@braddunn.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package dice
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestResultsRoundTripThroughJSON(t *testing.T) {
+	for _, expression := range []string{"4d6kh3+2", "1d6!>=5ro1min2", "6d10>=8f1", "3d{hit,miss}", "1d20cs20cf1"} {
+		result, err := RollDice(NewSeededRand(5), expression, nil)
+		if err != nil {
+			t.Fatalf("RollDice(%q) returned error: %v", expression, err)
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			t.Fatalf("json.Marshal(%q) returned error: %v", expression, err)
+		}
+		var decoded TotalRollResult
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("json.Unmarshal(%s) returned error: %v", data, err)
+		}
+		if !reflect.DeepEqual(decoded, result) {
+			t.Errorf("%q didn't round trip:\n got %+v\nwant %+v", expression, decoded, result)
+		}
+	}
+}
+
+func TestCompareText(t *testing.T) {
+	for _, c := range []Compare{{Op: ">=", Value: 9}, {Op: "<", Value: 3}, {Op: "=", Value: 1}, {Op: ">", Value: -2}} {
+		text, err := c.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText(%v) returned error: %v", c, err)
+		}
+		var decoded Compare
+		if err := decoded.UnmarshalText(text); err != nil || decoded != c {
+			t.Errorf("Compare %q decoded to %v, %v; want %v", text, decoded, err, c)
+		}
+	}
+	var c Compare
+	if err := c.UnmarshalText([]byte("~5")); err == nil {
+		t.Error("UnmarshalText(~5) expected an error")
+	}
+}